@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+	"wally/config"
+	"wally/internal/database"
+	"wally/internal/handler"
+	"wally/internal/llm"
+	"wally/internal/metrics"
+	"wally/internal/rag"
+	"wally/internal/service"
+	"wally/internal/sessions"
+	"wally/internal/usage"
+	"wally/pkg/wasender"
+	"wally/pkg/whisper"
+)
+
+// App reúne os componentes do servidor, construídos a partir da configuração. É o único
+// lugar onde as implementações concretas (Postgres, Gemini, WaSenderAPI, whisper) são escolhidas.
+type App struct {
+	cfg       config.Config
+	db        *sql.DB
+	llm       llm.Client
+	messenger *wasender.Client
+	bot       *service.Bot
+	knowledge rag.KnowledgeRepository
+}
+
+// NewApp abre a conexão com o banco e monta o bot com suas dependências.
+func NewApp(ctx context.Context, cfg config.Config) (*App, error) {
+	db, err := database.Open(ctx, cfg.DatabaseUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	usageRepo := usage.NewPostgresRepository(db, cfg.DBTimeout)
+	gemini := newLLMClient(cfg, usageRepo)
+	classifier, err := service.NewIntentClassifier(gemini, cfg.IntentPromptVersion)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	messenger := wasender.NewClient(wasender.Options{
+		ApiKey:        cfg.ApiKey,
+		Interactive:   cfg.Interactive,
+		Timeout:       cfg.WaSenderTimeout,
+		MaxMediaBytes: cfg.MaxMediaBytes,
+	})
+
+	var embedder llm.Embedder
+	if cfg.RAGEmbeddings {
+		embedder = usage.NewEmbedderMeter(llm.NewGeminiEmbedder(cfg.GeminiKey, cfg.GeminiEmbeddingModel, cfg.GeminiTimeout),
+			usageRepo, usageOptions(cfg, cfg.GeminiEmbeddingModel))
+	}
+
+	knowledge := rag.NewPostgresKnowledgeRepository(db, cfg.DBTimeout, rag.RetrievalOptions{
+		TopK:          cfg.RAGTopK,
+		MinScore:      cfg.RAGMinScore,
+		MinSimilarity: cfg.RAGMinSimilarity,
+		HalfLife:      cfg.RAGHalfLife,
+	}, embedder)
+
+	store := sessions.NewStoreWithHistory(sessions.HistoryOptions{
+		MaxTurns: cfg.HistoryMaxTurns,
+		MaxAge:   cfg.HistoryMaxAge,
+	})
+
+	bot := service.NewBot(service.Deps{
+		Knowledge:   knowledge,
+		Sessions:    store,
+		LLM:         gemini,
+		Classifier:  classifier,
+		Messenger:   messenger,
+		Media:       service.WaSenderMediaDownloader{Client: messenger},
+		Transcriber: whisper.NewClient(cfg.WhisperUrl, cfg.WhisperTimeout),
+
+		HistoryTokenBudget: cfg.HistoryTokenBudget,
+	})
+
+	return &App{cfg: cfg, db: db, llm: gemini, messenger: messenger, bot: bot, knowledge: knowledge}, nil
+}
+
+// newLLMClient monta o cliente da Gemini com novas tentativas, circuit breaker e
+// contabilização de uso e, se houver um modelo reserva, com hedge para ele. O uso é
+// contabilizado por modelo, por dentro do hedge, para que as duas chamadas de um hedge
+// contem; e por fora das novas tentativas, que só têm uma resposta bem-sucedida.
+func newLLMClient(cfg config.Config, usageRepo usage.Repository) llm.Client {
+	retry := llm.RetryOptions{
+		MaxAttempts: cfg.LLMMaxAttempts,
+		BaseDelay:   cfg.LLMRetryBaseDelay,
+		MaxDelay:    cfg.LLMRetryMaxDelay,
+	}
+	breaker := llm.BreakerOptions{Threshold: cfg.LLMBreakerThreshold, Cooldown: cfg.LLMBreakerCooldown}
+	resilient := func(model string) llm.Client {
+		gemini := llm.NewGemini(cfg.GeminiKey, model, cfg.GeminiTimeout)
+		return usage.NewMeter(llm.NewBreaker(llm.NewRetrying(gemini, retry), model, breaker), usageRepo, usageOptions(cfg, model))
+	}
+
+	client := resilient(cfg.GeminiModel)
+	if cfg.GeminiFallbackModel != "" && cfg.GeminiFallbackModel != cfg.GeminiModel {
+		client = llm.NewHedged(client, resilient(cfg.GeminiFallbackModel), cfg.LLMHedgeDelay)
+	}
+	return client
+}
+
+// usageOptions são as opções de contabilização de uso do modelo.
+func usageOptions(cfg config.Config, model string) usage.MeterOptions {
+	return usage.MeterOptions{
+		DailyTokenLimit: cfg.LLMUserDailyTokens,
+		Location:        cfg.UsageLocation(),
+		Model:           model,
+	}
+}
+
+// PruneKnowledge apaga o conhecimento sem uso há mais de RAGPruneAfter, na chamada e depois
+// uma vez por dia, até ctx ser cancelado.
+func (a *App) PruneKnowledge(ctx context.Context) {
+	if a.cfg.RAGPruneAfter <= 0 {
+		return
+	}
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for {
+		if _, err := a.knowledge.PruneKnowledge(ctx, time.Now().Add(-a.cfg.RAGPruneAfter)); err != nil {
+			slog.Error("erro ao apagar conhecimento sem uso", slog.Any("error", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Routes registra os endpoints HTTP. O processamento das mensagens é entregue ao dispatcher.
+func (a *App) Routes(dispatcher handler.Dispatcher) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/webhook", handler.NewWebhookHandler(a.bot, dispatcher, a.cfg.MaxBodyBytes, a.cfg.MessageTimeout))
+	mux.HandleFunc("/healthz", handler.HealthzHandler)
+	mux.Handle("/readyz", handler.NewReadyzHandler([]handler.Check{
+		{Name: "database", Run: a.db.PingContext},
+		handler.Cached(handler.Check{Name: "llm", Run: a.llm.Ping}, a.cfg.ReadinessCacheTTL),
+		{Name: "messaging", Run: func(ctx context.Context) error { return a.messenger.CheckCredentials() }},
+	}, a.cfg.ReadinessTimeout))
+	mux.HandleFunc("/version", handler.VersionHandler)
+	mux.Handle("/metrics", metrics.Handler())
+	return mux
+}
+
+// Close libera os recursos abertos por NewApp.
+func (a *App) Close() error {
+	return a.db.Close()
+}
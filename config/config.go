@@ -1,109 +1,528 @@
 package config
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
+	"net/url"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
-type webhookPayload struct {
-	URL string `json:"url"`
+// Config reúne toda a configuração do Wally. É montada uma única vez na inicialização
+// e injetada nos componentes que precisam dela.
+type Config struct {
+	ApiKey      string `yaml:"api_key"`
+	DatabaseUrl string `yaml:"database_url"`
+	GeminiKey   string `yaml:"gemini_key"`
+	GeminiModel string `yaml:"gemini_model"`
+	WhisperUrl  string `yaml:"whisper_url"`
+	Interactive bool   `yaml:"interactive"`
+	Port        string `yaml:"port"`
+
+	// IntentPromptVersion escolhe a versão do prompt de classificação de intenção
+	// (vazio para a versão padrão). Compare versões com "wally eval" antes de trocar.
+	IntentPromptVersion string `yaml:"intent_prompt_version"`
+
+	// LogLevel é o nível mínimo dos logs (debug, info, warn, error). DebugMode desativa a
+	// redação de telefones e textos de mensagens e só deve ser usado em desenvolvimento.
+	LogLevel  string `yaml:"log_level"`
+	DebugMode bool   `yaml:"debug_mode"`
+
+	// Tracing com OpenTelemetry exportado via OTLP/HTTP (host:porta do coletor).
+	TracingEnabled     bool    `yaml:"tracing_enabled"`
+	OtlpEndpoint       string  `yaml:"otlp_endpoint"`
+	OtlpInsecure       bool    `yaml:"otlp_insecure"`
+	TracingSampleRatio float64 `yaml:"tracing_sample_ratio"`
+
+	// TunnelMode define como obter a URL pública do webhook: "none" (usa PublicUrl),
+	// "ngrok" (sobe um túnel local) ou "skip" (não registra o webhook).
+	TunnelMode   string        `yaml:"tunnel_mode"`
+	PublicUrl    string        `yaml:"public_url"`
+	NgrokBin     string        `yaml:"ngrok_bin"`
+	NgrokApiUrl  string        `yaml:"ngrok_api_url"`
+	NgrokTimeout time.Duration `yaml:"ngrok_timeout"`
+
+	// AutoMigrate aplica as migrações pendentes na inicialização do servidor.
+	AutoMigrate bool `yaml:"auto_migrate"`
+
+	// Recuperação do RAG: quantas entradas enviar ao LLM e a relevância mínima (0 a 1)
+	// de cada uma em relação à mensagem atual.
+	RAGTopK     int     `yaml:"rag_top_k"`
+	RAGMinScore float64 `yaml:"rag_min_score"`
+
+	// RAGEmbeddings também compara o conhecimento por significado, com embeddings gerados
+	// por GeminiEmbeddingModel; RAGMinSimilarity é a similaridade de cosseno mínima (0 a 1).
+	RAGEmbeddings        bool    `yaml:"rag_embeddings"`
+	RAGMinSimilarity     float64 `yaml:"rag_min_similarity"`
+	GeminiEmbeddingModel string  `yaml:"gemini_embedding_model"`
+
+	// O peso de uma entrada de conhecimento cai pela metade a cada RAGHalfLife sem uso, e
+	// entradas sem uso há mais de RAGPruneAfter são apagadas (0 desativa cada um).
+	RAGHalfLife   time.Duration `yaml:"rag_half_life"`
+	RAGPruneAfter time.Duration `yaml:"rag_prune_after"`
+
+	// Histórico da conversa enviado ao LLM: até HistoryMaxTurns mensagens por usuário, das
+	// últimas HistoryMaxAge, cortadas para caber em HistoryTokenBudget tokens estimados
+	// (HistoryMaxTurns 0 desativa o histórico).
+	HistoryMaxTurns    int           `yaml:"history_max_turns"`
+	HistoryMaxAge      time.Duration `yaml:"history_max_age"`
+	HistoryTokenBudget int           `yaml:"history_token_budget"`
+
+	// Resiliência das chamadas à Gemini: até LLMMaxAttempts tentativas com espera exponencial
+	// entre LLMRetryBaseDelay e LLMRetryMaxDelay, e um circuit breaker que abre após
+	// LLMBreakerThreshold falhas seguidas por LLMBreakerCooldown. Com GeminiFallbackModel,
+	// chamadas que falham ou demoram mais que LLMHedgeDelay (0: só as que falham) também vão
+	// para esse modelo.
+	LLMMaxAttempts      int           `yaml:"llm_max_attempts"`
+	LLMRetryBaseDelay   time.Duration `yaml:"llm_retry_base_delay"`
+	LLMRetryMaxDelay    time.Duration `yaml:"llm_retry_max_delay"`
+	LLMBreakerThreshold int           `yaml:"llm_breaker_threshold"`
+	LLMBreakerCooldown  time.Duration `yaml:"llm_breaker_cooldown"`
+	GeminiFallbackModel string        `yaml:"gemini_fallback_model"`
+	LLMHedgeDelay       time.Duration `yaml:"llm_hedge_delay"`
+
+	// Uso do LLM: cada usuário pode gastar até LLMUserDailyTokens tokens por dia (0 desativa
+	// o limite); depois disso, o bot usa só as regras locais até o dia seguinte. UsageTimezone
+	// é o fuso em que o dia começa, também usado nos relatórios de "wally usage".
+	LLMUserDailyTokens int    `yaml:"llm_user_daily_tokens"`
+	UsageTimezone      string `yaml:"usage_timezone"`
+
+	// Servidor HTTP e processamento em segundo plano.
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	MaxBodyBytes    int64         `yaml:"max_body_bytes"`
+	MaxMediaBytes   int64         `yaml:"max_media_bytes"` // Maior imagem ou áudio baixado
+	Workers         int           `yaml:"workers"`
+	WorkerQueue     int           `yaml:"worker_queue"` // Capacidade total, dividida entre os workers
+
+	// Probes de saúde: tempo máximo do /readyz e cache da verificação do provedor de IA.
+	ReadinessTimeout  time.Duration `yaml:"readiness_timeout"`
+	ReadinessCacheTTL time.Duration `yaml:"readiness_cache_ttl"`
+
+	// Prazos por etapa: o processamento de uma mensagem inteira é limitado por MessageTimeout
+	// e cada chamada externa pelo timeout da respectiva dependência.
+	MessageTimeout  time.Duration `yaml:"message_timeout"`
+	DBTimeout       time.Duration `yaml:"db_timeout"`
+	GeminiTimeout   time.Duration `yaml:"gemini_timeout"`
+	WaSenderTimeout time.Duration `yaml:"wasender_timeout"`
+	WhisperTimeout  time.Duration `yaml:"whisper_timeout"`
 }
 
-type Config struct {
-	ApiKey      string `json:"apikey"`
-	DatabaseUrl string `json:"database_url"`
-	GeminiKey   string `json:"gemini_key"`
+// Default retorna a configuração com os valores padrão.
+func Default() Config {
+	return Config{
+		GeminiModel: "gemini-1.5-flash-latest",
+		WhisperUrl:  "http://127.0.0.1:8081",
+		Port:        "8080",
+		LogLevel:    "info",
+
+		OtlpEndpoint:       "localhost:4318",
+		OtlpInsecure:       true,
+		TracingSampleRatio: 1,
+
+		TunnelMode:   "ngrok",
+		NgrokBin:     "ngrok",
+		NgrokApiUrl:  "http://127.0.0.1:4040",
+		NgrokTimeout: 15 * time.Second,
+		AutoMigrate:  true,
+		RAGTopK:      3,
+		RAGMinScore:  0.3,
+
+		RAGEmbeddings:        true,
+		RAGMinSimilarity:     0.75,
+		GeminiEmbeddingModel: "text-embedding-004",
+		RAGHalfLife:          90 * 24 * time.Hour,
+		RAGPruneAfter:        180 * 24 * time.Hour,
+
+		HistoryMaxTurns:    10,
+		HistoryMaxAge:      30 * time.Minute,
+		HistoryTokenBudget: 1000,
+
+		LLMMaxAttempts:      3,
+		LLMRetryBaseDelay:   500 * time.Millisecond,
+		LLMRetryMaxDelay:    8 * time.Second,
+		LLMBreakerThreshold: 5,
+		LLMBreakerCooldown:  30 * time.Second,
+		LLMHedgeDelay:       4 * time.Second,
+
+		LLMUserDailyTokens: 200_000,
+		UsageTimezone:      "America/Sao_Paulo",
+
+		ReadTimeout:     10 * time.Second,
+		WriteTimeout:    15 * time.Second,
+		IdleTimeout:     60 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
+		MaxBodyBytes:    1 << 20,
+		MaxMediaBytes:   16 << 20,
+		Workers:         8,
+		WorkerQueue:     100,
+
+		ReadinessTimeout:  3 * time.Second,
+		ReadinessCacheTTL: 30 * time.Second,
+
+		MessageTimeout:  2 * time.Minute,
+		DBTimeout:       5 * time.Second,
+		GeminiTimeout:   30 * time.Second,
+		WaSenderTimeout: 15 * time.Second,
+		WhisperTimeout:  60 * time.Second,
+	}
 }
 
-// Load carrega as variaveis de ambiente do arquivo .env
-func Load() Config {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Erro ao carregar o arquivo .env:", err)
+// Load monta a configuração a partir, em ordem de precedência crescente, dos valores padrão,
+// de um arquivo YAML opcional (-config ou WALLY_CONFIG), do arquivo .env opcional, das
+// variáveis de ambiente e das flags de linha de comando. Todos os erros de validação são
+// retornados juntos.
+func Load(args []string) (Config, error) {
+	cfg, err := Parse(args)
+	if err != nil {
+		return cfg, err
 	}
+	return cfg, cfg.Validate()
+}
 
-	api := os.Getenv("API_KEY")
-	if api == "" {
-		log.Fatal("variavel de ambiente API_KEY nao encontrada")
+// Parse monta a configuração como Load, mas sem validar os campos obrigatórios. Útil para
+// subcomandos que só precisam de parte da configuração, como o "migrate".
+func Parse(args []string) (Config, error) {
+	cfg := Default()
+
+	// O .env apenas preenche variáveis ainda não definidas no ambiente.
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return cfg, fmt.Errorf("erro ao carregar o arquivo .env: %w", err)
 	}
 
-	dbUrl := os.Getenv("DATABASE_URL")
-	if dbUrl == "" {
-		log.Fatal("variavel de ambiente DATABASE_URL nao encontrada")
+	fs := flag.NewFlagSet("wally", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("WALLY_CONFIG"), "arquivo de configuração YAML (.yaml ou .yml) opcional")
+	port := fs.String("port", "", "porta HTTP do servidor")
+	geminiModel := fs.String("gemini-model", "", "modelo da Gemini")
+	whisperURL := fs.String("whisper-url", "", "URL do servidor whisper.cpp")
+	tunnelMode := fs.String("tunnel", "", "modo da URL pública: none, ngrok ou skip")
+	publicURL := fs.String("public-url", "", "URL pública usada no modo none")
+	logLevel := fs.String("log-level", "", "nível de log: debug, info, warn ou error")
+	interactive := fs.String("interactive", "", "habilita mensagens interativas da WaSenderAPI (true/false)")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
 	}
 
-	gemini := os.Getenv("GEMINI_KEY")
-	if gemini == "" {
-		log.Fatal("variavel de ambiente GEMINI_KEY nao encontrada")
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return cfg, err
+		}
 	}
 
-	cfg := Config{
-		ApiKey:      api,
-		DatabaseUrl: dbUrl,
-		GeminiKey:   gemini,
+	var errs []error
+	envString(&cfg.ApiKey, "API_KEY")
+	envString(&cfg.DatabaseUrl, "DATABASE_URL")
+	envString(&cfg.GeminiKey, "GEMINI_KEY")
+	envString(&cfg.GeminiModel, "GEMINI_MODEL")
+	envString(&cfg.GeminiEmbeddingModel, "GEMINI_EMBEDDING_MODEL")
+	envString(&cfg.GeminiFallbackModel, "GEMINI_FALLBACK_MODEL")
+	envString(&cfg.UsageTimezone, "USAGE_TIMEZONE")
+	envString(&cfg.IntentPromptVersion, "INTENT_PROMPT_VERSION")
+	envString(&cfg.WhisperUrl, "WHISPER_URL")
+	envString(&cfg.Port, "PORT")
+	envString(&cfg.LogLevel, "LOG_LEVEL")
+	envString(&cfg.OtlpEndpoint, "OTLP_ENDPOINT")
+	envString(&cfg.TunnelMode, "TUNNEL_MODE")
+	envString(&cfg.PublicUrl, "PUBLIC_URL")
+	envString(&cfg.NgrokBin, "NGROK_BIN")
+	envString(&cfg.NgrokApiUrl, "NGROK_API_URL")
+	errs = append(errs,
+		envBool(&cfg.Interactive, "WASENDER_INTERACTIVE"),
+		envBool(&cfg.DebugMode, "DEBUG_MODE"),
+		envBool(&cfg.AutoMigrate, "AUTO_MIGRATE"),
+		envBool(&cfg.TracingEnabled, "TRACING_ENABLED"),
+		envBool(&cfg.OtlpInsecure, "OTLP_INSECURE"),
+		envFloat(&cfg.TracingSampleRatio, "TRACING_SAMPLE_RATIO"),
+		envDuration(&cfg.MessageTimeout, "MESSAGE_TIMEOUT"),
+		envDuration(&cfg.DBTimeout, "DB_TIMEOUT"),
+		envDuration(&cfg.GeminiTimeout, "GEMINI_TIMEOUT"),
+		envDuration(&cfg.WaSenderTimeout, "WASENDER_TIMEOUT"),
+		envDuration(&cfg.WhisperTimeout, "WHISPER_TIMEOUT"),
+		envDuration(&cfg.NgrokTimeout, "NGROK_TIMEOUT"),
+		envDuration(&cfg.ReadTimeout, "HTTP_READ_TIMEOUT"),
+		envDuration(&cfg.WriteTimeout, "HTTP_WRITE_TIMEOUT"),
+		envDuration(&cfg.IdleTimeout, "HTTP_IDLE_TIMEOUT"),
+		envDuration(&cfg.ShutdownTimeout, "SHUTDOWN_TIMEOUT"),
+		envInt64(&cfg.MaxBodyBytes, "MAX_BODY_BYTES"),
+		envInt64(&cfg.MaxMediaBytes, "MAX_MEDIA_BYTES"),
+		envInt(&cfg.Workers, "WORKERS"),
+		envInt(&cfg.WorkerQueue, "WORKER_QUEUE"),
+		envInt(&cfg.RAGTopK, "RAG_TOP_K"),
+		envFloat(&cfg.RAGMinScore, "RAG_MIN_SCORE"),
+		envBool(&cfg.RAGEmbeddings, "RAG_EMBEDDINGS"),
+		envFloat(&cfg.RAGMinSimilarity, "RAG_MIN_SIMILARITY"),
+		envDuration(&cfg.RAGHalfLife, "RAG_HALF_LIFE"),
+		envDuration(&cfg.RAGPruneAfter, "RAG_PRUNE_AFTER"),
+		envInt(&cfg.HistoryMaxTurns, "HISTORY_MAX_TURNS"),
+		envDuration(&cfg.HistoryMaxAge, "HISTORY_MAX_AGE"),
+		envInt(&cfg.HistoryTokenBudget, "HISTORY_TOKEN_BUDGET"),
+		envInt(&cfg.LLMMaxAttempts, "LLM_MAX_ATTEMPTS"),
+		envDuration(&cfg.LLMRetryBaseDelay, "LLM_RETRY_BASE_DELAY"),
+		envDuration(&cfg.LLMRetryMaxDelay, "LLM_RETRY_MAX_DELAY"),
+		envInt(&cfg.LLMBreakerThreshold, "LLM_BREAKER_THRESHOLD"),
+		envDuration(&cfg.LLMBreakerCooldown, "LLM_BREAKER_COOLDOWN"),
+		envDuration(&cfg.LLMHedgeDelay, "LLM_HEDGE_DELAY"),
+		envInt(&cfg.LLMUserDailyTokens, "LLM_USER_DAILY_TOKENS"),
+		envDuration(&cfg.ReadinessTimeout, "READINESS_TIMEOUT"),
+		envDuration(&cfg.ReadinessCacheTTL, "READINESS_CACHE_TTL"),
+	)
+
+	if *port != "" {
+		cfg.Port = *port
+	}
+	if *geminiModel != "" {
+		cfg.GeminiModel = *geminiModel
+	}
+	if *whisperURL != "" {
+		cfg.WhisperUrl = *whisperURL
+	}
+	if *logLevel != "" {
+		cfg.LogLevel = *logLevel
+	}
+	if *tunnelMode != "" {
+		cfg.TunnelMode = *tunnelMode
+	}
+	if *publicURL != "" {
+		cfg.PublicUrl = *publicURL
+	}
+	if *interactive != "" {
+		errs = append(errs, parseBool(&cfg.Interactive, "-interactive", *interactive))
 	}
 
-	return cfg
+	return cfg, errors.Join(errs...)
 }
 
-// StartNgrok inicia o ngrok e altera o webhook na WaSenderAPI
-func StartNgrok(port string) (string, error) {
-	cfg := Load()
+// Validate verifica os campos obrigatórios e os formatos, reportando todos os problemas juntos.
+func (c Config) Validate() error {
+	var errs []error
 
-	cmd := exec.Command("ngrok", "http", port)
-	err := cmd.Start()
-	if err != nil {
-		return "", err
+	if c.ApiKey == "" {
+		errs = append(errs, errors.New("variavel de ambiente API_KEY nao encontrada"))
 	}
-
-	time.Sleep(2 * time.Second)
-
-	resp, err := http.Get("http://127.0.0.1:4040/api/tunnels")
-	if err != nil {
-		return "", err
+	if c.DatabaseUrl == "" {
+		errs = append(errs, errors.New("variavel de ambiente DATABASE_URL nao encontrada"))
+	}
+	if c.GeminiKey == "" {
+		errs = append(errs, errors.New("variavel de ambiente GEMINI_KEY nao encontrada"))
+	}
+	if c.GeminiModel == "" {
+		errs = append(errs, errors.New("modelo da Gemini nao pode ser vazio"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL invalido: %q", c.LogLevel))
+	}
+	if c.TracingEnabled && c.OtlpEndpoint == "" {
+		errs = append(errs, errors.New("OTLP_ENDPOINT obrigatorio com TRACING_ENABLED=true"))
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("TRACING_SAMPLE_RATIO deve estar entre 0 e 1: %v", c.TracingSampleRatio))
+	}
+	if p, err := strconv.Atoi(c.Port); err != nil || p <= 0 || p > 65535 {
+		errs = append(errs, fmt.Errorf("porta invalida: %q", c.Port))
+	}
+	if u, err := url.Parse(c.WhisperUrl); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("WHISPER_URL invalida: %q", c.WhisperUrl))
+	}
+	if c.RAGTopK < 1 {
+		errs = append(errs, fmt.Errorf("RAG_TOP_K deve ser positivo: %d", c.RAGTopK))
+	}
+	if c.RAGMinScore < 0 || c.RAGMinScore > 1 {
+		errs = append(errs, fmt.Errorf("RAG_MIN_SCORE deve estar entre 0 e 1: %v", c.RAGMinScore))
+	}
+	if c.RAGHalfLife < 0 {
+		errs = append(errs, fmt.Errorf("RAG_HALF_LIFE nao pode ser negativo: %s", c.RAGHalfLife))
+	}
+	if c.RAGPruneAfter < 0 {
+		errs = append(errs, fmt.Errorf("RAG_PRUNE_AFTER nao pode ser negativo: %s", c.RAGPruneAfter))
+	}
+	if c.HistoryMaxTurns < 0 {
+		errs = append(errs, fmt.Errorf("HISTORY_MAX_TURNS nao pode ser negativo: %d", c.HistoryMaxTurns))
+	}
+	if c.HistoryMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("HISTORY_MAX_AGE nao pode ser negativo: %s", c.HistoryMaxAge))
+	}
+	if c.HistoryMaxTurns > 0 && c.HistoryTokenBudget <= 0 {
+		errs = append(errs, fmt.Errorf("HISTORY_TOKEN_BUDGET deve ser positivo: %d", c.HistoryTokenBudget))
+	}
+	if c.LLMMaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("LLM_MAX_ATTEMPTS deve ser pelo menos 1: %d", c.LLMMaxAttempts))
+	}
+	if c.LLMRetryMaxDelay < c.LLMRetryBaseDelay {
+		errs = append(errs, fmt.Errorf("LLM_RETRY_MAX_DELAY (%s) deve ser maior ou igual a LLM_RETRY_BASE_DELAY (%s)", c.LLMRetryMaxDelay, c.LLMRetryBaseDelay))
+	}
+	if c.LLMBreakerThreshold < 1 {
+		errs = append(errs, fmt.Errorf("LLM_BREAKER_THRESHOLD deve ser pelo menos 1: %d", c.LLMBreakerThreshold))
+	}
+	if c.LLMHedgeDelay < 0 {
+		errs = append(errs, fmt.Errorf("LLM_HEDGE_DELAY nao pode ser negativo: %s", c.LLMHedgeDelay))
+	}
+	if c.LLMUserDailyTokens < 0 {
+		errs = append(errs, fmt.Errorf("LLM_USER_DAILY_TOKENS nao pode ser negativo: %d", c.LLMUserDailyTokens))
+	}
+	if _, err := time.LoadLocation(c.UsageTimezone); err != nil || c.UsageTimezone == "" || c.UsageTimezone == "Local" {
+		errs = append(errs, fmt.Errorf("USAGE_TIMEZONE invalido: %q (use um nome da base IANA, ex: America/Sao_Paulo)", c.UsageTimezone))
+	}
+	if c.RAGEmbeddings {
+		if c.RAGMinSimilarity <= 0 || c.RAGMinSimilarity > 1 {
+			errs = append(errs, fmt.Errorf("RAG_MIN_SIMILARITY deve estar entre 0 (exclusive) e 1: %v", c.RAGMinSimilarity))
+		}
+		if c.GeminiEmbeddingModel == "" {
+			errs = append(errs, errors.New("modelo de embedding da Gemini nao pode ser vazio com RAG_EMBEDDINGS"))
+		}
+	}
+	switch c.TunnelMode {
+	case "none":
+		if u, err := url.Parse(c.PublicUrl); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("PUBLIC_URL invalida para TUNNEL_MODE=none: %q", c.PublicUrl))
+		}
+	case "ngrok":
+		if c.NgrokBin == "" {
+			errs = append(errs, errors.New("NGROK_BIN nao pode ser vazio"))
+		}
+	case "skip":
+	default:
+		errs = append(errs, fmt.Errorf("TUNNEL_MODE invalido: %q (use none, ngrok ou skip)", c.TunnelMode))
+	}
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"MESSAGE_TIMEOUT", c.MessageTimeout},
+		{"DB_TIMEOUT", c.DBTimeout},
+		{"GEMINI_TIMEOUT", c.GeminiTimeout},
+		{"WASENDER_TIMEOUT", c.WaSenderTimeout},
+		{"WHISPER_TIMEOUT", c.WhisperTimeout},
+		{"NGROK_TIMEOUT", c.NgrokTimeout},
+		{"HTTP_READ_TIMEOUT", c.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"READINESS_TIMEOUT", c.ReadinessTimeout},
+		{"READINESS_CACHE_TTL", c.ReadinessCacheTTL},
+		{"LLM_RETRY_BASE_DELAY", c.LLMRetryBaseDelay},
+		{"LLM_BREAKER_COOLDOWN", c.LLMBreakerCooldown},
+	}
+	for _, t := range timeouts {
+		if t.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s deve ser maior que zero", t.name))
+		}
+	}
+	if c.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("MAX_BODY_BYTES deve ser maior que zero"))
+	}
+	if c.MaxMediaBytes <= 0 {
+		errs = append(errs, errors.New("MAX_MEDIA_BYTES deve ser maior que zero"))
+	}
+	if c.Workers <= 0 {
+		errs = append(errs, errors.New("WORKERS deve ser maior que zero"))
+	}
+	if c.WorkerQueue < 0 {
+		errs = append(errs, errors.New("WORKER_QUEUE nao pode ser negativo"))
 	}
-	defer resp.Body.Close()
 
-	var result map[string]any
+	return errors.Join(errs...)
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+// UsageLocation retorna o fuso de UsageTimezone, ou UTC se ele for inválido.
+func (c Config) UsageLocation() *time.Location {
+	loc, err := time.LoadLocation(c.UsageTimezone)
+	if err != nil || c.UsageTimezone == "" || c.UsageTimezone == "Local" {
+		return time.UTC
 	}
+	return loc
+}
 
-	tunnels := result["tunnels"].([]any)
+// loadFile lê o arquivo de configuração, que precisa ser YAML (.yaml ou .yml). Outras
+// extensões são recusadas em vez de decodificadas como YAML por engano.
+func loadFile(path string, cfg *Config) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	default:
+		return fmt.Errorf("arquivo de configuração %s: formato não suportado, use YAML (.yaml ou .yml)", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("erro ao ler arquivo de configuração %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("erro ao decodificar arquivo de configuração %s: %w", path, err)
+	}
+	return nil
+}
 
-	for _, t := range tunnels {
-		tunnel := t.(map[string]any)
-		if tunnel["proto"] == "https" {
-			publicURL := tunnel["public_url"].(string)
+func envString(dst *string, key string) {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		*dst = v
+	}
+}
 
-			payload := webhookPayload{URL: publicURL + "/webhook"}
-			body, _ := json.Marshal(payload)
+func envBool(dst *bool, key string) error {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return parseBool(dst, key, v)
+	}
+	return nil
+}
 
-			req, _ := http.NewRequest("POST", "https://wasenderapi.com/api/set-webhook", bytes.NewBuffer(body))
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Authorization", "Bearer "+cfg.ApiKey)
+func parseBool(dst *bool, name string, v string) error {
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s invalido: %q", name, v)
+	}
+	*dst = b
+	return nil
+}
 
-			client := &http.Client{}
-			resp, err := client.Do(req)
+func envDuration(dst *time.Duration, key string) error {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s invalido: %q", key, v)
+		}
+		*dst = d
+	}
+	return nil
+}
 
-			if err != nil {
-				return "", err
-			}
+func envInt(dst *int, key string) error {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s invalido: %q", key, v)
+		}
+		*dst = n
+	}
+	return nil
+}
 
-			defer resp.Body.Close()
-			fmt.Println("🔗 Webhook registrado na WaSenderAPI:", publicURL+"/webhook")
-			return publicURL, nil
+func envInt64(dst *int64, key string) error {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s invalido: %q", key, v)
 		}
+		*dst = n
 	}
+	return nil
+}
 
-	return "", errors.New("nenhum túnel HTTPS encontrado")
+func envFloat(dst *float64, key string) error {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%s invalido: %q", key, v)
+		}
+		*dst = f
+	}
+	return nil
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"wally/config"
+	"wally/internal/eval"
+	"wally/internal/llm"
+	"wally/internal/logging"
+	"wally/internal/service"
+)
+
+const evalUsage = `Uso: wally eval [flags]
+
+Roda o conjunto rotulado de mensagens pela classificação de intenção e relata acurácia
+por ação, parâmetros exatos, latência e custo. Com várias versões de prompt (ou -baseline),
+compara cada execução com a primeira e lista regressões e correções.
+
+Exemplos:
+  wally eval -prompt v1 -out base.json
+  wally eval -prompt v2 -baseline base.json -fail-on-regression
+  wally eval -prompt v1,v2
+
+Flags:`
+
+// runEval implementa o subcomando "wally eval" e retorna o código de saída. As credenciais
+// e o modelo vêm da mesma configuração do servidor (.env, WALLY_CONFIG e variáveis de ambiente).
+func runEval(args []string) int {
+	fs := flag.NewFlagSet("wally eval", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), evalUsage)
+		fs.PrintDefaults()
+	}
+	datasetPath := fs.String("dataset", "", "conjunto em JSON Lines (padrão: conjunto pt-BR embutido)")
+	prompts := fs.String("prompt", "", "versões de prompt separadas por vírgula (padrão: a configurada); disponíveis: "+strings.Join(service.IntentPromptVersions(), ", "))
+	model := fs.String("model", "", "modelo da Gemini (padrão: GEMINI_MODEL)")
+	concurrency := fs.Int("concurrency", 4, "chamadas simultâneas ao LLM")
+	out := fs.String("out", "", "grava os resultados em JSON (com várias versões, a versão é acrescentada ao nome)")
+	baseline := fs.String("baseline", "", "resultados gravados com -out para comparação")
+	priceInput := fs.Float64("price-input", 0, "preço em USD por milhão de tokens de entrada (padrão: tabela de referência)")
+	priceOutput := fs.Float64("price-output", 0, "preço em USD por milhão de tokens de saída (padrão: tabela de referência)")
+	failOnRegression := fs.Bool("fail-on-regression", false, "sai com código 1 se algum exemplo regredir em relação à base")
+	verbose := fs.Bool("v", false, "lista os exemplos classificados errado")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	cfg, err := config.Parse(nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuração inválida:\n%v\n", err)
+		return 1
+	}
+	if cfg.GeminiKey == "" {
+		fmt.Fprintln(os.Stderr, "variavel de ambiente GEMINI_KEY nao encontrada")
+		return 1
+	}
+	if *model != "" {
+		cfg.GeminiModel = *model
+	}
+	versions := []string{cfg.IntentPromptVersion}
+	if *prompts != "" {
+		versions = strings.Split(*prompts, ",")
+	}
+	if err := logging.Setup(os.Stderr, cfg.LogLevel, cfg.DebugMode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	examples, err := eval.LoadDataset(*datasetPath)
+	if err != nil {
+		slog.Error("erro ao carregar o conjunto de avaliação", slog.Any("error", err))
+		return 1
+	}
+	datasetName := eval.DefaultDatasetName
+	if *datasetPath != "" {
+		datasetName = *datasetPath
+	}
+
+	var price *llm.Price
+	if *priceInput > 0 || *priceOutput > 0 {
+		price = &llm.Price{Input: *priceInput, Output: *priceOutput}
+	} else if p, ok := llm.PriceFor(cfg.GeminiModel); ok {
+		price = &p
+	}
+
+	var runs []eval.Run
+	if *baseline != "" {
+		base, err := eval.LoadRun(*baseline)
+		if err != nil {
+			slog.Error("erro ao carregar a base", slog.Any("error", err))
+			return 1
+		}
+		runs = append(runs, base)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	client := llm.NewGemini(cfg.GeminiKey, cfg.GeminiModel, cfg.GeminiTimeout)
+	for _, version := range versions {
+		run, err := eval.Evaluate(ctx, client, strings.TrimSpace(version), examples, *concurrency)
+		if err != nil {
+			slog.Error("erro ao avaliar o prompt", slog.String("prompt_version", version), slog.Any("error", err))
+			return 1
+		}
+		run.Model = cfg.GeminiModel
+		run.Dataset = datasetName
+		run.Price = price
+
+		eval.PrintSummary(os.Stdout, run, eval.Summarize(run), *verbose)
+		fmt.Println()
+
+		if *out != "" {
+			path := *out
+			if len(versions) > 1 {
+				ext := filepath.Ext(path)
+				path = strings.TrimSuffix(path, ext) + "." + run.PromptVersion + ext
+			}
+			if err := eval.SaveRun(path, run); err != nil {
+				slog.Error("erro ao gravar resultados", slog.Any("error", err))
+				return 1
+			}
+		}
+		runs = append(runs, run)
+	}
+
+	regressions := 0
+	for _, candidate := range runs[1:] {
+		diff := eval.Compare(runs[0], candidate)
+		eval.PrintDiff(os.Stdout, diff)
+		fmt.Println()
+		regressions += len(diff.Regressed)
+	}
+	if *failOnRegression && regressions > 0 {
+		return 1
+	}
+	return 0
+}
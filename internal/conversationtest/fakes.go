@@ -0,0 +1,245 @@
+package conversationtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"wally/internal/domain"
+	"wally/internal/llm"
+	"wally/internal/service"
+	"wally/pkg/wasender"
+)
+
+// Reply é uma resposta roteirizada do LLM: o texto devolvido ou o erro da chamada.
+type Reply struct {
+	Text  string
+	Usage llm.Usage
+	Err   error
+}
+
+// Intent monta a resposta do LLM para a classificação de intenção.
+func Intent(action string, parameters map[string]string) Reply {
+	return IntentWithError(action, parameters, "")
+}
+
+// IntentWithError monta a resposta do LLM para a classificação de intenção com o campo "error".
+func IntentWithError(action string, parameters map[string]string, intentError string) Reply {
+	if parameters == nil {
+		parameters = map[string]string{}
+	}
+	text, err := json.Marshal(service.IntentResponse{Action: action, Parameters: parameters, Error: intentError})
+	if err != nil {
+		panic(err)
+	}
+	return Reply{Text: string(text)}
+}
+
+// Call é uma chamada recebida pelo LLM falso.
+type Call struct {
+	Purpose string
+	Request llm.Request
+}
+
+// Prompt concatena o texto de todas as partes do conteúdo da requisição, sem as instruções
+// do sistema (veja System).
+func (c Call) Prompt() string {
+	var b strings.Builder
+	for _, content := range c.Request.Contents {
+		for _, part := range content.Parts {
+			b.WriteString(part.Text)
+		}
+	}
+	return b.String()
+}
+
+// System concatena o texto das instruções do sistema, se houver.
+func (c Call) System() string {
+	if c.Request.SystemInstruction == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, part := range c.Request.SystemInstruction.Parts {
+		b.WriteString(part.Text)
+	}
+	return b.String()
+}
+
+// ScriptedLLM devolve as respostas roteirizadas na ordem em que foram enfileiradas e
+// registra as chamadas recebidas.
+type ScriptedLLM struct {
+	mu         sync.Mutex
+	replies    []Reply
+	calls      []Call
+	unexpected int
+}
+
+var errNoScriptedReply = errors.New("LLM falso sem respostas roteirizadas")
+
+// Push enfileira respostas para as próximas chamadas.
+func (l *ScriptedLLM) Push(replies ...Reply) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.replies = append(l.replies, replies...)
+}
+
+func (l *ScriptedLLM) GenerateContent(ctx context.Context, purpose string, req llm.Request) (llm.Response, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls = append(l.calls, Call{Purpose: purpose, Request: req})
+	if len(l.replies) == 0 {
+		l.unexpected++
+		return llm.Response{}, fmt.Errorf("%w (purpose %q)", errNoScriptedReply, purpose)
+	}
+	reply := l.replies[0]
+	l.replies = l.replies[1:]
+	if reply.Err != nil {
+		return llm.Response{}, reply.Err
+	}
+	return llm.Response{Text: reply.Text, Model: "scripted", Usage: reply.Usage}, nil
+}
+
+func (l *ScriptedLLM) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Calls retorna as chamadas recebidas até agora.
+func (l *ScriptedLLM) Calls() []Call {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Call(nil), l.calls...)
+}
+
+// pending retorna quantas respostas não foram consumidas e quantas chamadas ficaram sem
+// resposta roteirizada, zerando os contadores para o próximo turno.
+func (l *ScriptedLLM) pending() (unused int, unexpected int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	unused, unexpected = len(l.replies), l.unexpected
+	l.replies, l.unexpected = nil, 0
+	return unused, unexpected
+}
+
+// Message é uma mensagem enviada pelo bot ao usuário.
+type Message struct {
+	Number  string
+	Kind    string // "text", "buttons" ou "list"
+	Text    string
+	Options []string // Botões ou itens de lista, no formato "Título (id)"
+}
+
+// String formata a mensagem como aparece nas transcrições.
+func (m Message) String() string {
+	if len(m.Options) == 0 {
+		return m.Text
+	}
+	return fmt.Sprintf("%s\n[%s] %s", m.Text, m.Kind, strings.Join(m.Options, " | "))
+}
+
+// RecordingMessenger captura as mensagens enviadas pelo bot.
+type RecordingMessenger struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (m *RecordingMessenger) SendMessage(ctx context.Context, number string, message string) {
+	m.record(Message{Number: number, Kind: "text", Text: message})
+}
+
+func (m *RecordingMessenger) SendButtons(ctx context.Context, number string, text string, buttons []wasender.Button) {
+	options := make([]string, len(buttons))
+	for i, b := range buttons {
+		options[i] = fmt.Sprintf("%s (%s)", b.Text, b.ID)
+	}
+	m.record(Message{Number: number, Kind: "buttons", Text: text, Options: options})
+}
+
+func (m *RecordingMessenger) SendList(ctx context.Context, number string, text string, buttonText string, sections []wasender.ListSection) {
+	var options []string
+	for _, section := range sections {
+		for _, row := range section.Rows {
+			options = append(options, fmt.Sprintf("%s (%s)", row.Title, row.ID))
+		}
+	}
+	m.record(Message{Number: number, Kind: "list", Text: text, Options: options})
+}
+
+func (m *RecordingMessenger) record(msg Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+}
+
+// Take retorna as mensagens enviadas desde a última chamada.
+func (m *RecordingMessenger) Take() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sent := m.sent
+	m.sent = nil
+	return sent
+}
+
+// StaticMedia devolve sempre o mesmo conteúdo para qualquer mídia.
+type StaticMedia struct {
+	Data []byte
+}
+
+func (s StaticMedia) DownloadMedia(ctx context.Context, media domain.MediaMessage) ([]byte, error) {
+	return s.Data, nil
+}
+
+// ScriptedTranscriber devolve as transcrições enfileiradas, na ordem.
+type ScriptedTranscriber struct {
+	mu          sync.Mutex
+	transcripts []string
+}
+
+// Push enfileira transcrições para as próximas notas de voz.
+func (s *ScriptedTranscriber) Push(transcripts ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transcripts = append(s.transcripts, transcripts...)
+}
+
+func (s *ScriptedTranscriber) Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.transcripts) == 0 {
+		return "", errors.New("transcritor falso sem transcrições roteirizadas")
+	}
+	transcript := s.transcripts[0]
+	s.transcripts = s.transcripts[1:]
+	return transcript, nil
+}
+
+// ConceptEmbedder é um embedder determinístico: cada grupo de palavras sinônimas é uma
+// dimensão do vetor, e o texto é representado pela contagem de palavras de cada grupo.
+// Basta para que "rango" e "almoço" fiquem próximos sem nenhuma palavra em comum.
+type ConceptEmbedder struct {
+	Concepts [][]string
+}
+
+// DefaultConcepts são os grupos de sinônimos usados pelo harness.
+var DefaultConcepts = [][]string{
+	{"rango", "comida", "almoço", "almoco", "jantar", "lanche", "restaurante"},
+	{"mercado", "mercadinho", "supermercado", "feira"},
+	{"uber", "taxi", "ônibus", "onibus", "gasolina", "transporte"},
+}
+
+func (e *ConceptEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	vector := make([]float32, len(e.Concepts))
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) })
+	for _, word := range words {
+		for i, concept := range e.Concepts {
+			if slices.Contains(concept, word) {
+				vector[i]++
+			}
+		}
+	}
+	return vector, nil
+}
@@ -0,0 +1,106 @@
+// Package conversationtest monta o bot com dependências falsas (LLM roteirizado, mensageiro
+// que captura as respostas, repositório em memória) e conduz conversas de ponta a ponta
+// pelo handler do webhook, comparando as respostas com arquivos golden.
+package conversationtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+	"wally/internal/handler"
+	"wally/internal/rag"
+	"wally/internal/service"
+	"wally/internal/sessions"
+	"wally/internal/usage"
+	"wally/internal/worker"
+)
+
+// payloadDir guarda payloads reais da WaSenderAPI usados nos turnos.
+const payloadDir = "testdata/payloads"
+
+// DailyTokenLimit é o limite diário de tokens por usuário aplicado pelo harness.
+const DailyTokenLimit = 10_000
+
+// Harness conecta o handler do webhook ao bot com dependências falsas.
+type Harness struct {
+	LLM         *ScriptedLLM
+	Messenger   *RecordingMessenger
+	Knowledge   *rag.MemoryKnowledgeRepository
+	Sessions    *sessions.Store
+	Transcriber *ScriptedTranscriber
+	Usage       *usage.MemoryRepository
+	Bot         *service.Bot
+
+	handler http.Handler
+	turns   int
+}
+
+// New cria um harness com estado vazio.
+func New() *Harness {
+	h := &Harness{
+		LLM:         &ScriptedLLM{},
+		Messenger:   &RecordingMessenger{},
+		Knowledge:   rag.NewMemoryKnowledgeRepository(&ConceptEmbedder{Concepts: DefaultConcepts}),
+		Sessions:    sessions.NewStore(),
+		Transcriber: &ScriptedTranscriber{},
+		Usage:       usage.NewMemoryRepository(),
+	}
+	h.Bot = service.NewBot(service.Deps{
+		Knowledge:   h.Knowledge,
+		Sessions:    h.Sessions,
+		LLM:         usage.NewMeter(h.LLM, h.Usage, usage.MeterOptions{DailyTokenLimit: DailyTokenLimit}),
+		Messenger:   h.Messenger,
+		Media:       StaticMedia{Data: []byte("fake-media")},
+		Transcriber: h.Transcriber,
+	})
+	h.handler = handler.NewWebhookHandler(h.Bot, inlineDispatcher{}, 1<<20, time.Minute)
+	return h
+}
+
+// inlineDispatcher executa o job dentro da própria requisição, para que as respostas
+// estejam disponíveis assim que o webhook retorna.
+type inlineDispatcher struct{}
+
+func (inlineDispatcher) Submit(_ string, job worker.Job) bool {
+	job(context.Background())
+	return true
+}
+
+// Deliver envia o payload ao webhook e retorna o status HTTP.
+func (h *Harness) Deliver(payload []byte) int {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	rec := httptest.NewRecorder()
+	h.handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+// LoadPayload lê um payload gravado em testdata/payloads.
+func LoadPayload(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(payloadDir, name))
+}
+
+// TextPayload monta uma mensagem de texto a partir do payload gravado text.json. Cada
+// chamada usa um ID de mensagem diferente, como acontece na WaSenderAPI.
+func (h *Harness) TextPayload(text string) ([]byte, error) {
+	raw, err := LoadPayload("text.json")
+	if err != nil {
+		return nil, err
+	}
+	var payload handler.WebhookPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("payload text.json inválido: %w", err)
+	}
+
+	h.turns++
+	id := fmt.Sprintf("3EB0TURN%012d", h.turns)
+	payload.Data.Messages.Key.ID = id
+	payload.Data.Messages.ID = id
+	payload.Data.Messages.Message.Conversation = text
+	return json.Marshal(payload)
+}
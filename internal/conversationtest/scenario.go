@@ -0,0 +1,111 @@
+package conversationtest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "regrava os arquivos golden das conversas")
+
+// goldenDir guarda as transcrições esperadas de cada cenário.
+const goldenDir = "testdata/golden"
+
+// Scenario é uma conversa de vários turnos com um usuário.
+type Scenario struct {
+	Name  string
+	Turns []Turn
+}
+
+// Turn é uma mensagem do usuário e o roteiro das dependências para processá-la.
+// Informe Text ou Payload.
+type Turn struct {
+	Text       string  // Mensagem de texto, montada a partir de testdata/payloads/text.json
+	Payload    string  // Payload gravado em testdata/payloads
+	LLM        []Reply // Respostas do LLM, na ordem das chamadas feitas neste turno
+	Transcript string  // Transcrição devolvida pelo speech-to-text, em notas de voz
+}
+
+// Run conduz o cenário pelo webhook e compara a transcrição da conversa com
+// testdata/golden/<Name>.golden (use -update para regravá-la). Todas as respostas
+// roteirizadas do LLM precisam ser consumidas no turno em que foram declaradas.
+// Retorna o harness para verificações adicionais, como o conhecimento salvo.
+func Run(t *testing.T, sc Scenario) *Harness {
+	t.Helper()
+	h := New()
+
+	var transcript strings.Builder
+	for i, turn := range sc.Turns {
+		payload, input := turnPayload(t, h, turn)
+		h.LLM.Push(turn.LLM...)
+		if turn.Transcript != "" {
+			h.Transcriber.Push(turn.Transcript)
+		}
+
+		if status := h.Deliver(payload); status != 200 {
+			t.Fatalf("turno %d: webhook retornou status %d", i+1, status)
+		}
+		if unused, unexpected := h.LLM.pending(); unused > 0 || unexpected > 0 {
+			t.Errorf("turno %d: %d respostas do LLM não consumidas, %d chamadas sem roteiro", i+1, unused, unexpected)
+		}
+
+		transcript.WriteString(">>> " + input + "\n")
+		for _, msg := range h.Messenger.Take() {
+			writeReply(&transcript, msg.String())
+		}
+	}
+
+	assertGolden(t, sc.Name, transcript.String())
+	return h
+}
+
+// writeReply escreve a resposta do bot com as linhas seguintes indentadas.
+func writeReply(b *strings.Builder, reply string) {
+	for i, line := range strings.Split(reply, "\n") {
+		switch {
+		case i == 0:
+			b.WriteString("<<< " + line)
+		case line != "":
+			b.WriteString("    " + line)
+		}
+		b.WriteString("\n")
+	}
+}
+
+func turnPayload(t *testing.T, h *Harness, turn Turn) ([]byte, string) {
+	t.Helper()
+	if turn.Payload != "" {
+		payload, err := LoadPayload(turn.Payload)
+		if err != nil {
+			t.Fatalf("erro ao ler payload: %v", err)
+		}
+		return payload, "[" + turn.Payload + "]"
+	}
+	payload, err := h.TextPayload(turn.Text)
+	if err != nil {
+		t.Fatalf("erro ao montar payload de texto: %v", err)
+	}
+	return payload, turn.Text
+}
+
+func assertGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join(goldenDir, name+".golden")
+
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("erro ao gravar golden: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("erro ao ler golden (rode com -update para criá-lo): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("conversa diferente do golden %s\n--- obtido ---\n%s\n--- esperado ---\n%s", path, got, want)
+	}
+}
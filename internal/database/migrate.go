@@ -0,0 +1,211 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID identifica o advisory lock que impede duas instâncias de migrarem ao mesmo tempo.
+const migrationLockID = 7_345_911_202
+
+var migrationName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration é uma alteração versionada do schema, com seus scripts de ida e volta.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus indica se uma migração já foi aplicada e quando.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations lê as migrações embutidas no binário, ordenadas por versão.
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao listar migrações: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("nome de migração inválido: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+
+		content, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler migração %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("versão %d usada por duas migrações: %s e %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migração %04d_%s sem script up", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrateUp aplica todas as migrações pendentes, cada uma em sua própria transação.
+func MigrateUp(ctx context.Context, db *sql.DB) error {
+	return withMigrationLock(ctx, db, func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error {
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, m.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+				return fmt.Errorf("erro ao aplicar migração %04d_%s: %w", m.Version, m.Name, err)
+			}
+			slog.Info("migração aplicada", slog.Int("version", m.Version), slog.String("name", m.Name))
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverte as últimas steps migrações aplicadas.
+func MigrateDown(ctx context.Context, db *sql.DB, steps int) error {
+	return withMigrationLock(ctx, db, func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error {
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migração %04d_%s não possui script down", m.Version, m.Name)
+			}
+			if err := runMigration(ctx, conn, m.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+				return fmt.Errorf("erro ao reverter migração %04d_%s: %w", m.Version, m.Name, err)
+			}
+			slog.Info("migração revertida", slog.Int("version", m.Version), slog.String("name", m.Name))
+			steps--
+		}
+		return nil
+	})
+}
+
+// MigrationsStatus lista todas as migrações conhecidas e se já foram aplicadas.
+func MigrationsStatus(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	err := withMigrationLock(ctx, db, func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error {
+		for _, m := range migrations {
+			s := MigrationStatus{Migration: m}
+			if at, ok := applied[m.Version]; ok {
+				s.AppliedAt = &at
+			}
+			status = append(status, s)
+		}
+		return nil
+	})
+	return status, err
+}
+
+// withMigrationLock obtém uma conexão dedicada, segura o advisory lock durante fn e garante
+// que a tabela schema_migrations exista antes de ler as versões aplicadas.
+func withMigrationLock(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+
+	// O advisory lock é por sessão, então todas as operações precisam usar a mesma conexão.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao obter conexão para migrações: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("erro ao obter lock de migração: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			slog.Error("erro ao liberar lock de migração", slog.Any("error", err))
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version BIGINT PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    )`); err != nil {
+		return fmt.Errorf("erro ao criar tabela schema_migrations: %w", err)
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, migrations, applied)
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler migrações aplicadas: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("erro ao ler migração aplicada: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// runMigration executa o script e o registro em schema_migrations na mesma transação.
+func runMigration(ctx context.Context, conn *sql.Conn, script string, bookkeeping string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,14 @@
+-- Tabela de conhecimento aprendido pelo RAG. IF NOT EXISTS mantém compatibilidade com
+-- instalações criadas antes das migrações versionadas.
+CREATE TABLE IF NOT EXISTS knowledge_entries (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    original_query TEXT,
+    clarification_query TEXT,
+    resulting_action VARCHAR(255),
+    resulting_parameters JSONB, -- Usar JSONB para armazenar os parâmetros
+    timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_entries_user_timestamp
+    ON knowledge_entries (user_id, timestamp DESC);
@@ -0,0 +1,4 @@
+-- A extensão pg_trgm é mantida: pode estar em uso por outros objetos do banco.
+DROP INDEX IF EXISTS idx_knowledge_entries_original_trgm;
+DROP INDEX IF EXISTS idx_knowledge_entries_search;
+ALTER TABLE knowledge_entries DROP COLUMN IF EXISTS search_vector;
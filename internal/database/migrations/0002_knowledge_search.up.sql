@@ -0,0 +1,16 @@
+-- Busca por relevância no RAG: full-text em português sobre a mensagem original e o
+-- esclarecimento, e similaridade por trigramas (pg_trgm) sobre a mensagem original.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE knowledge_entries
+    ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        to_tsvector('portuguese'::regconfig,
+            coalesce(original_query, '') || ' ' || coalesce(clarification_query, ''))
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_entries_search
+    ON knowledge_entries USING GIN (search_vector);
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_entries_original_trgm
+    ON knowledge_entries USING GIN (original_query gin_trgm_ops);
@@ -0,0 +1,3 @@
+-- A extensão vector é mantida: pode estar em uso por outros objetos do banco.
+ALTER TABLE knowledge_entries DROP COLUMN IF EXISTS embedding_vector;
+ALTER TABLE knowledge_entries DROP COLUMN IF EXISTS embedding;
@@ -0,0 +1,19 @@
+-- Embeddings das entradas de conhecimento. A coluna embedding (DOUBLE PRECISION[]) existe
+-- sempre e é comparada em Go; quando a extensão pgvector está disponível, o mesmo vetor é
+-- guardado em embedding_vector e a distância de cosseno é calculada no banco.
+ALTER TABLE knowledge_entries ADD COLUMN IF NOT EXISTS embedding DOUBLE PRECISION[];
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'vector') THEN
+        CREATE EXTENSION IF NOT EXISTS vector;
+        -- Sem dimensão fixa para aceitar qualquer modelo de embedding. As buscas são
+        -- sempre filtradas por usuário, então não há índice vetorial.
+        ALTER TABLE knowledge_entries ADD COLUMN IF NOT EXISTS embedding_vector vector;
+    ELSE
+        RAISE NOTICE 'extensão pgvector indisponível: embeddings serão comparados na aplicação';
+    END IF;
+EXCEPTION WHEN insufficient_privilege THEN
+    RAISE NOTICE 'sem permissão para criar a extensão pgvector: embeddings serão comparados na aplicação';
+END
+$$;
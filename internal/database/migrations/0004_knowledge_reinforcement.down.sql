@@ -0,0 +1,5 @@
+-- As entradas consolidadas não são separadas novamente.
+DROP INDEX IF EXISTS idx_knowledge_entries_user_normalized;
+ALTER TABLE knowledge_entries DROP COLUMN IF EXISTS last_used_at;
+ALTER TABLE knowledge_entries DROP COLUMN IF EXISTS hit_count;
+ALTER TABLE knowledge_entries DROP COLUMN IF EXISTS normalized_query;
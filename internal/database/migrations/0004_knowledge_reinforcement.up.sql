@@ -0,0 +1,31 @@
+-- Consolidação do conhecimento: cada usuário tem no máximo uma entrada por mensagem original
+-- normalizada (minúsculas, espaços colapsados; a migração 0007 tira também os valores), reforçada
+-- a cada uso confirmado (hit_count, last_used_at).
+ALTER TABLE knowledge_entries ADD COLUMN IF NOT EXISTS normalized_query TEXT;
+ALTER TABLE knowledge_entries ADD COLUMN IF NOT EXISTS hit_count INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE knowledge_entries ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE knowledge_entries SET
+    normalized_query = lower(regexp_replace(btrim(coalesce(original_query, '')), '\s+', ' ', 'g')),
+    last_used_at = coalesce(timestamp, CURRENT_TIMESTAMP);
+
+-- Entradas repetidas viram uma só: fica a mais recente, com a contagem de todas.
+UPDATE knowledge_entries e SET hit_count = d.total
+FROM (
+    SELECT user_id, normalized_query, count(*) AS total
+    FROM knowledge_entries
+    GROUP BY user_id, normalized_query
+    HAVING count(*) > 1
+) d
+WHERE e.user_id = d.user_id AND e.normalized_query = d.normalized_query;
+
+DELETE FROM knowledge_entries older
+USING knowledge_entries newer
+WHERE older.user_id = newer.user_id
+  AND older.normalized_query = newer.normalized_query
+  AND (coalesce(older.timestamp, '-infinity'), older.id) < (coalesce(newer.timestamp, '-infinity'), newer.id);
+
+ALTER TABLE knowledge_entries ALTER COLUMN normalized_query SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_knowledge_entries_user_normalized
+    ON knowledge_entries (user_id, normalized_query);
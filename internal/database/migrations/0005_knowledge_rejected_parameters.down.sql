@@ -0,0 +1 @@
+ALTER TABLE knowledge_entries DROP COLUMN IF EXISTS rejected_parameters;
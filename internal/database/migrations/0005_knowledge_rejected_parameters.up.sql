@@ -0,0 +1,3 @@
+-- Exemplos negativos: parâmetros que o usuário corrigiu (ex: a categoria errada), para que o
+-- contexto enviado ao LLM diga "não X, mas Y".
+ALTER TABLE knowledge_entries ADD COLUMN IF NOT EXISTS rejected_parameters JSONB;
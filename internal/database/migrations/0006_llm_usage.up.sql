@@ -0,0 +1,17 @@
+-- Uso do LLM por chamada, para acompanhar o custo por usuário e aplicar o limite diário.
+-- user_id fica vazio nas chamadas que não são de um usuário; cost_usd é nulo quando o preço
+-- do modelo é desconhecido.
+CREATE TABLE IF NOT EXISTS llm_usage (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL DEFAULT '',
+    purpose VARCHAR(64) NOT NULL,
+    model VARCHAR(255) NOT NULL,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    total_tokens INTEGER NOT NULL DEFAULT 0,
+    cost_usd NUMERIC(14, 8),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_llm_usage_user_created ON llm_usage (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_llm_usage_created ON llm_usage (created_at);
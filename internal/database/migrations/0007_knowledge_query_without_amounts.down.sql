@@ -0,0 +1,3 @@
+-- Volta à regra da migração 0004. As entradas consolidadas não são separadas novamente.
+UPDATE knowledge_entries SET
+    normalized_query = lower(regexp_replace(btrim(coalesce(original_query, '')), '\s+', ' ', 'g'));
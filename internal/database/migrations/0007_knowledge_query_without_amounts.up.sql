@@ -0,0 +1,31 @@
+-- A mensagem normalizada deixa de incluir os valores (mesma regra de rag.NormalizeQuery):
+-- "uber 30" e "uber 18" passam a ser a mesma entrada. Uma mensagem só com valores fica com eles.
+DROP INDEX IF EXISTS idx_knowledge_entries_user_normalized;
+
+UPDATE knowledge_entries SET normalized_query = coalesce(
+    nullif(btrim(regexp_replace(
+        regexp_replace(lower(coalesce(original_query, '')), '(^|\s)(r\$[0-9.,]*|[0-9][0-9.,]*)(?=\s|$)', ' ', 'g'),
+        '\s+', ' ', 'g')), ''),
+    lower(regexp_replace(btrim(coalesce(original_query, '')), '\s+', ' ', 'g')));
+
+-- Entradas que passaram a ser repetidas viram uma só: fica a mais recente, somando os usos
+-- das que tinham o mesmo mapeamento que ela.
+UPDATE knowledge_entries e SET hit_count = d.total
+FROM (
+    SELECT user_id, normalized_query, resulting_action, lower(resulting_parameters->>'category') AS category, sum(hit_count) AS total
+    FROM knowledge_entries
+    GROUP BY user_id, normalized_query, resulting_action, lower(resulting_parameters->>'category')
+    HAVING count(*) > 1
+) d
+WHERE e.user_id = d.user_id AND e.normalized_query = d.normalized_query
+  AND e.resulting_action IS NOT DISTINCT FROM d.resulting_action
+  AND lower(e.resulting_parameters->>'category') IS NOT DISTINCT FROM d.category;
+
+DELETE FROM knowledge_entries older
+USING knowledge_entries newer
+WHERE older.user_id = newer.user_id
+  AND older.normalized_query = newer.normalized_query
+  AND (coalesce(older.timestamp, '-infinity'), older.id) < (coalesce(newer.timestamp, '-infinity'), newer.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_knowledge_entries_user_normalized
+    ON knowledge_entries (user_id, normalized_query);
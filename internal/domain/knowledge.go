@@ -10,5 +10,8 @@ type KnowledgeEntry struct {
 	ClarificationQuery  string
 	ResultingAction     string
 	ResultingParameters map[string]string
+	RejectedParameters  map[string]string // Parâmetros corrigidos pelo usuário (exemplo negativo), se houver
 	Timestamp           time.Time
+	HitCount            int       // Quantas vezes a entrada foi aprendida ou levou a uma ação confirmada
+	LastUsedAt          time.Time // Último aprendizado ou uso confirmado
 }
@@ -0,0 +1,20 @@
+package domain
+
+import "encoding/json"
+
+// MediaMessage descreve uma mídia (imagem, áudio) recebida pelo webhook.
+type MediaMessage struct {
+	MessageID string
+	Type      string // Chave da mídia no payload, ex: "imageMessage"
+	MimeType  string
+	Caption   string
+	Raw       json.RawMessage // Objeto original da mídia, necessário para descriptografar
+}
+
+// Receipt representa os dados extraídos de um comprovante ou nota fiscal.
+type Receipt struct {
+	Total    float64 `json:"total"`
+	Merchant string  `json:"merchant"`
+	Date     string  `json:"date"`
+	Category string  `json:"category"`
+}
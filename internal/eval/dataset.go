@@ -0,0 +1,78 @@
+// Package eval avalia offline a classificação de intenção: roda um conjunto rotulado de
+// mensagens pelo LLM com uma versão de prompt, mede acerto, latência e custo, e compara
+// os resultados entre versões.
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+//go:embed datasets/intents_pt_br.jsonl
+var defaultDataset []byte
+
+// DefaultDatasetName identifica o conjunto embutido nos resultados.
+const DefaultDatasetName = "embutido:intents_pt_br.jsonl"
+
+// Example é uma mensagem rotulada com a intenção esperada. Só os parâmetros informados
+// são verificados; parâmetros livres como "description" normalmente ficam de fora.
+type Example struct {
+	ID         string            `json:"id"`
+	Message    string            `json:"message"`
+	Context    string            `json:"context,omitempty"` // Conhecimento aprendido enviado junto, como no bot
+	Action     string            `json:"action"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// LoadDataset lê um conjunto em JSON Lines (um Example por linha). Com path vazio,
+// usa o conjunto pt-BR embutido no binário.
+func LoadDataset(path string) ([]Example, error) {
+	if path == "" {
+		return parseDataset(bytes.NewReader(defaultDataset))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o conjunto de avaliação: %w", err)
+	}
+	defer f.Close()
+	return parseDataset(f)
+}
+
+func parseDataset(r io.Reader) ([]Example, error) {
+	var examples []Example
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var ex Example
+		if err := json.Unmarshal(scanner.Bytes(), &ex); err != nil {
+			return nil, fmt.Errorf("linha %d: %w", line, err)
+		}
+		switch {
+		case ex.ID == "":
+			return nil, fmt.Errorf("linha %d: exemplo sem id", line)
+		case ex.Message == "" || ex.Action == "":
+			return nil, fmt.Errorf("linha %d (%s): message e action são obrigatórios", line, ex.ID)
+		case seen[ex.ID]:
+			return nil, fmt.Errorf("linha %d: id duplicado %q", line, ex.ID)
+		}
+		seen[ex.ID] = true
+		examples = append(examples, ex)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao ler o conjunto de avaliação: %w", err)
+	}
+	if len(examples) == 0 {
+		return nil, errors.New("conjunto de avaliação vazio")
+	}
+	return examples, nil
+}
@@ -0,0 +1,131 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Change é um exemplo cujo resultado mudou entre duas execuções.
+type Change struct {
+	ID       string
+	Message  string
+	Expected string
+	Before   string
+	After    string
+}
+
+// Diff compara uma execução candidata com a execução de base, exemplo a exemplo.
+type Diff struct {
+	Base      Run
+	Candidate Run
+	Regressed []Change // Corretos na base e errados na candidata
+	Fixed     []Change // Errados na base e corretos na candidata
+	Missing   int      // Exemplos presentes em só uma das execuções
+}
+
+// Compare casa os exemplos das duas execuções pelo ID. Um exemplo está correto quando a
+// ação e, se houver, todos os parâmetros esperados batem.
+func Compare(base, candidate Run) Diff {
+	d := Diff{Base: base, Candidate: candidate}
+
+	baseByID := make(map[string]Result, len(base.Results))
+	for _, r := range base.Results {
+		baseByID[r.ID] = r
+	}
+	matched := 0
+	for _, after := range candidate.Results {
+		before, ok := baseByID[after.ID]
+		if !ok {
+			d.Missing++
+			continue
+		}
+		matched++
+
+		change := Change{
+			ID:       after.ID,
+			Message:  after.Message,
+			Expected: describe(after.ExpectedAction, after.ExpectedParameters, ""),
+			Before:   describe(before.Action, before.Parameters, before.Error),
+			After:    describe(after.Action, after.Parameters, after.Error),
+		}
+		switch wasOK, isOK := correct(before), correct(after); {
+		case wasOK && !isOK:
+			d.Regressed = append(d.Regressed, change)
+		case !wasOK && isOK:
+			d.Fixed = append(d.Fixed, change)
+		}
+	}
+	d.Missing += len(base.Results) - matched
+	return d
+}
+
+func correct(r Result) bool {
+	return r.ActionMatch && (len(r.ExpectedParameters) == 0 || r.ParamsMatch)
+}
+
+func describe(action string, params map[string]string, errMsg string) string {
+	if errMsg != "" {
+		return "erro: " + errMsg
+	}
+	if len(params) == 0 {
+		return action
+	}
+	return fmt.Sprintf("%s %v", action, params)
+}
+
+// PrintDiff escreve a comparação entre as execuções: variação das métricas e os exemplos
+// que regrediram ou foram corrigidos.
+func PrintDiff(w io.Writer, d Diff) {
+	base, cand := Summarize(d.Base), Summarize(d.Candidate)
+	fmt.Fprintf(w, "Comparação: prompt %s (%s) → prompt %s (%s)\n\n", d.Base.PromptVersion, d.Base.Model, d.Candidate.PromptVersion, d.Candidate.Model)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MÉTRICA\tBASE\tCANDIDATO\tΔ")
+	baseActions := make(map[string]ActionStats)
+	for _, stats := range base.PerAction {
+		baseActions[stats.Action] = stats
+	}
+	for _, stats := range append(cand.PerAction, cand.Overall) {
+		before := baseActions[stats.Action]
+		if stats.Action == "total" {
+			before = base.Overall
+		}
+		fmt.Fprintf(tw, "acurácia %s\t%s\t%s\t%s\n", stats.Action, percent(before.Accuracy()), percent(stats.Accuracy()), deltaPoints(before.Accuracy(), stats.Accuracy()))
+	}
+	fmt.Fprintf(tw, "parâmetros exatos\t%s\t%s\t%s\n", percent(base.ParamMatchRate()), percent(cand.ParamMatchRate()), deltaPoints(base.ParamMatchRate(), cand.ParamMatchRate()))
+	fmt.Fprintf(tw, "latência p50\t%s\t%s\t%s\n", roundDuration(base.LatencyP50), roundDuration(cand.LatencyP50), deltaDuration(base.LatencyP50, cand.LatencyP50))
+	fmt.Fprintf(tw, "latência p95\t%s\t%s\t%s\n", roundDuration(base.LatencyP95), roundDuration(cand.LatencyP95), deltaDuration(base.LatencyP95, cand.LatencyP95))
+	fmt.Fprintf(tw, "tokens de entrada\t%d\t%d\t%+d\n", base.Usage.PromptTokens, cand.Usage.PromptTokens, cand.Usage.PromptTokens-base.Usage.PromptTokens)
+	fmt.Fprintf(tw, "tokens de saída\t%d\t%d\t%+d\n", base.Usage.OutputTokens, cand.Usage.OutputTokens, cand.Usage.OutputTokens-base.Usage.OutputTokens)
+	if base.CostKnown && cand.CostKnown {
+		fmt.Fprintf(tw, "custo (US$)\t%.6f\t%.6f\t%+.6f\n", base.Cost, cand.Cost, cand.Cost-base.Cost)
+	}
+	tw.Flush()
+
+	if d.Missing > 0 {
+		fmt.Fprintf(w, "\n%d exemplos presentes em só uma das execuções foram ignorados.\n", d.Missing)
+	}
+	printChanges(w, "Regressões", d.Regressed)
+	printChanges(w, "Correções", d.Fixed)
+}
+
+func printChanges(w io.Writer, title string, changes []Change) {
+	fmt.Fprintf(w, "\n%s: %d\n", title, len(changes))
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s %q\n    esperado: %s\n    antes:    %s\n    depois:   %s\n", c.ID, c.Message, c.Expected, c.Before, c.After)
+	}
+}
+
+func deltaPoints(before, after float64) string {
+	return fmt.Sprintf("%+.1f p.p.", (after-before)*100)
+}
+
+func deltaDuration(before, after time.Duration) string {
+	d := roundDuration(after - before)
+	if d >= 0 {
+		return "+" + d.String()
+	}
+	return d.String()
+}
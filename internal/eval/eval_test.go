@@ -0,0 +1,148 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	ct "wally/internal/conversationtest"
+	"wally/internal/domain"
+	"wally/internal/llm"
+	"wally/internal/rag"
+	"wally/internal/service"
+)
+
+var examples = []Example{
+	{ID: "a", Message: "gastei 25,50 com café", Action: "add_expense", Parameters: map[string]string{"amount": "25.50", "category": "café"}},
+	{ID: "b", Message: "oi", Action: "show_menu"},
+	{ID: "c", Message: "mercado 80", Action: "add_expense", Parameters: map[string]string{"amount": "80", "category": "mercado"}},
+	{ID: "d", Message: "quero ver meu saldo", Action: "unknown_intent"},
+}
+
+func evaluate(t *testing.T, replies ...ct.Reply) Run {
+	t.Helper()
+	fake := &ct.ScriptedLLM{}
+	fake.Push(replies...)
+	run, err := Evaluate(context.Background(), fake, "", examples, 1)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	run.Price = &llm.Price{Input: 1, Output: 2}
+	return run
+}
+
+func withUsage(r ct.Reply) ct.Reply {
+	r.Usage = llm.Usage{PromptTokens: 1000, OutputTokens: 100, TotalTokens: 1100}
+	return r
+}
+
+func TestSummarize(t *testing.T) {
+	run := evaluate(t,
+		withUsage(ct.Intent("add_expense", map[string]string{"amount": "25,50", "category": "Café", "description": "café"})),
+		withUsage(ct.Intent("show_menu", nil)),
+		withUsage(ct.Intent("add_expense", map[string]string{"amount": "80", "category": "Supermercado"})),
+		ct.Reply{Err: errors.New("timeout")},
+	)
+	if run.PromptVersion != service.DefaultIntentPrompt {
+		t.Errorf("PromptVersion = %q, esperado %q", run.PromptVersion, service.DefaultIntentPrompt)
+	}
+
+	s := Summarize(run)
+	if s.Overall.Correct != 3 || s.Overall.Total != 4 {
+		t.Errorf("acertos = %d/%d, esperado 3/4", s.Overall.Correct, s.Overall.Total)
+	}
+	if s.ParamMatches != 1 || s.ParamTotal != 2 {
+		t.Errorf("parâmetros = %d/%d, esperado 1/2", s.ParamMatches, s.ParamTotal)
+	}
+	if s.Errors != 1 {
+		t.Errorf("erros = %d, esperado 1", s.Errors)
+	}
+	for _, stats := range s.PerAction {
+		if stats.Action == "unknown_intent" && stats.Correct != 0 {
+			t.Errorf("erro do LLM contado como acerto de unknown_intent")
+		}
+	}
+	// 3 chamadas com 1000 tokens de entrada e 100 de saída a US$1 e US$2 por milhão.
+	if want := 3 * (1000*1.0 + 100*2.0) / 1e6; !s.CostKnown || math.Abs(s.Cost-want) > 1e-12 {
+		t.Errorf("custo = %v, esperado %v", s.Cost, want)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := evaluate(t,
+		ct.Intent("add_expense", map[string]string{"amount": "25.50", "category": "café"}),
+		ct.Intent("unknown_intent", nil),
+		ct.Intent("add_expense", map[string]string{"amount": "80", "category": "mercado"}),
+		ct.Intent("unknown_intent", nil),
+	)
+	candidate := evaluate(t,
+		ct.Intent("add_expense", map[string]string{"amount": "25.50", "category": "café"}),
+		ct.Intent("show_menu", nil),
+		ct.Intent("add_expense", map[string]string{"amount": "8", "category": "mercado"}),
+		ct.Intent("unknown_intent", nil),
+	)
+
+	d := Compare(base, candidate)
+	if len(d.Regressed) != 1 || d.Regressed[0].ID != "c" {
+		t.Errorf("regressões = %+v, esperado só o exemplo c", d.Regressed)
+	}
+	if len(d.Fixed) != 1 || d.Fixed[0].ID != "b" {
+		t.Errorf("correções = %+v, esperado só o exemplo b", d.Fixed)
+	}
+
+	var out strings.Builder
+	PrintDiff(&out, d)
+	if !strings.Contains(out.String(), "Regressões: 1") {
+		t.Errorf("relatório sem a contagem de regressões:\n%s", out.String())
+	}
+}
+
+func TestEvaluateRejectsUnknownPrompt(t *testing.T) {
+	if _, err := Evaluate(context.Background(), &ct.ScriptedLLM{}, "v999", examples, 1); err == nil {
+		t.Error("esperava erro para versão de prompt inexistente")
+	}
+}
+
+func TestDefaultDataset(t *testing.T) {
+	examples, err := LoadDataset("")
+	if err != nil {
+		t.Fatalf("conjunto embutido inválido: %v", err)
+	}
+	actions := make(map[string]int)
+	for _, ex := range examples {
+		actions[ex.Action]++
+	}
+	for _, action := range []string{"add_expense", "show_menu", "unknown_intent"} {
+		if actions[action] == 0 {
+			t.Errorf("conjunto embutido sem exemplos de %s", action)
+		}
+	}
+}
+
+// Os contextos do conjunto padrão devem estar no formato de rag.BuildContext, para que a
+// avaliação meça o que o bot envia de fato.
+func TestDatasetContextsMatchBuildContext(t *testing.T) {
+	examples, err := LoadDataset("")
+	if err != nil {
+		t.Fatalf("LoadDataset: %v", err)
+	}
+	piece := regexp.MustCompile(`Anteriormente, quando o usuário disse algo como "([^"]*)"(?: e depois esclareceu com "([^"]*)")?, a intenção foi '(\w+)'(?: com categoria: ([^.]+))?\.\n`)
+	for _, ex := range examples {
+		if ex.Context == "" {
+			continue
+		}
+		var entries []domain.KnowledgeEntry
+		for _, m := range piece.FindAllStringSubmatch(ex.Context, -1) {
+			entry := domain.KnowledgeEntry{OriginalQuery: m[1], ClarificationQuery: m[2], ResultingAction: m[3]}
+			if m[4] != "" {
+				entry.ResultingParameters = map[string]string{"category": m[4]}
+			}
+			entries = append([]domain.KnowledgeEntry{entry}, entries...) // BuildContext recebe da mais para a menos relevante
+		}
+		if got := rag.BuildContext(entries); got != ex.Context {
+			t.Errorf("%s: contexto fora do formato de rag.BuildContext:\n%q\nesperado:\n%q", ex.ID, ex.Context, got)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+	"wally/internal/llm"
+)
+
+// ActionStats é o acerto da classificação para uma ação esperada.
+type ActionStats struct {
+	Action  string
+	Total   int
+	Correct int
+}
+
+// Accuracy é a fração de exemplos classificados corretamente.
+func (s ActionStats) Accuracy() float64 {
+	return ratio(s.Correct, s.Total)
+}
+
+// Summary resume uma execução.
+type Summary struct {
+	Overall   ActionStats
+	PerAction []ActionStats // Ordenado pelo nome da ação
+	Errors    int           // Chamadas ao LLM que falharam
+
+	// Exemplos com parâmetros esperados e quantos vieram com a ação e todos os parâmetros corretos.
+	ParamTotal   int
+	ParamMatches int
+
+	LatencyMean time.Duration
+	LatencyP50  time.Duration
+	LatencyP95  time.Duration
+	LatencyMax  time.Duration
+
+	Usage     llm.Usage
+	Cost      float64 // USD; zero quando o preço do modelo é desconhecido
+	CostKnown bool
+}
+
+// ParamMatchRate é a fração de exemplos com parâmetros esperados em que todos bateram.
+func (s Summary) ParamMatchRate() float64 {
+	return ratio(s.ParamMatches, s.ParamTotal)
+}
+
+// Summarize calcula as métricas da execução.
+func Summarize(run Run) Summary {
+	var s Summary
+	s.Overall.Action = "total"
+	perAction := make(map[string]*ActionStats)
+	latencies := make([]time.Duration, 0, len(run.Results))
+	var totalLatency time.Duration
+
+	for _, r := range run.Results {
+		stats, ok := perAction[r.ExpectedAction]
+		if !ok {
+			stats = &ActionStats{Action: r.ExpectedAction}
+			perAction[r.ExpectedAction] = stats
+		}
+		stats.Total++
+		s.Overall.Total++
+		if r.ActionMatch {
+			stats.Correct++
+			s.Overall.Correct++
+		}
+		if r.Error != "" {
+			s.Errors++
+		}
+		if len(r.ExpectedParameters) > 0 {
+			s.ParamTotal++
+			if r.ParamsMatch {
+				s.ParamMatches++
+			}
+		}
+		latencies = append(latencies, r.Latency)
+		totalLatency += r.Latency
+		s.Usage = s.Usage.Add(r.Usage)
+	}
+
+	for _, stats := range perAction {
+		s.PerAction = append(s.PerAction, *stats)
+	}
+	sort.Slice(s.PerAction, func(i, j int) bool { return s.PerAction[i].Action < s.PerAction[j].Action })
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		s.LatencyMean = totalLatency / time.Duration(len(latencies))
+		s.LatencyP50 = percentile(latencies, 0.50)
+		s.LatencyP95 = percentile(latencies, 0.95)
+		s.LatencyMax = latencies[len(latencies)-1]
+	}
+
+	if run.Price != nil {
+		s.Cost = run.Price.Cost(s.Usage)
+		s.CostKnown = true
+	}
+	return s
+}
+
+// percentile usa o método nearest-rank sobre latências já ordenadas.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// PrintSummary escreve o relatório da execução. Com verbose, lista também os exemplos errados.
+func PrintSummary(w io.Writer, run Run, s Summary, verbose bool) {
+	fmt.Fprintf(w, "Prompt %s · modelo %s · %s · %d exemplos\n\n", run.PromptVersion, run.Model, run.Dataset, s.Overall.Total)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "AÇÃO\tACERTOS\tACURÁCIA")
+	for _, stats := range append(s.PerAction, s.Overall) {
+		fmt.Fprintf(tw, "%s\t%d/%d\t%s\n", stats.Action, stats.Correct, stats.Total, percent(stats.Accuracy()))
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nParâmetros exatos: %d/%d (%s)\n", s.ParamMatches, s.ParamTotal, percent(s.ParamMatchRate()))
+	fmt.Fprintf(w, "Erros do LLM: %d\n", s.Errors)
+	fmt.Fprintf(w, "Latência: média %s · p50 %s · p95 %s · máx %s\n",
+		roundDuration(s.LatencyMean), roundDuration(s.LatencyP50), roundDuration(s.LatencyP95), roundDuration(s.LatencyMax))
+	fmt.Fprintf(w, "Tokens: %d entrada · %d saída\n", s.Usage.PromptTokens, s.Usage.OutputTokens)
+	if s.CostKnown {
+		fmt.Fprintf(w, "Custo: US$ %.6f (US$ %.4f por mil mensagens)\n", s.Cost, costPerThousand(s))
+	} else {
+		fmt.Fprintln(w, "Custo: preço do modelo desconhecido (informe -price-input e -price-output)")
+	}
+
+	if !verbose {
+		return
+	}
+	fmt.Fprintln(w, "\nExemplos errados:")
+	for _, r := range run.Results {
+		if r.ActionMatch && (len(r.ExpectedParameters) == 0 || r.ParamsMatch) {
+			continue
+		}
+		fmt.Fprintf(w, "  %s %q: esperado %s %v, obtido %s %v", r.ID, r.Message, r.ExpectedAction, r.ExpectedParameters, r.Action, r.Parameters)
+		if r.Error != "" {
+			fmt.Fprintf(w, " (erro: %s)", r.Error)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func costPerThousand(s Summary) float64 {
+	if s.Overall.Total == 0 {
+		return 0
+	}
+	return s.Cost / float64(s.Overall.Total) * 1000
+}
+
+func percent(v float64) string {
+	return fmt.Sprintf("%.1f%%", v*100)
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
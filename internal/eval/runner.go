@@ -0,0 +1,152 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"wally/internal/llm"
+	"wally/internal/service"
+)
+
+// Result é o resultado da classificação de um exemplo.
+type Result struct {
+	ID                 string            `json:"id"`
+	Message            string            `json:"message"`
+	ExpectedAction     string            `json:"expected_action"`
+	ExpectedParameters map[string]string `json:"expected_parameters,omitempty"`
+	Action             string            `json:"action"`
+	Parameters         map[string]string `json:"parameters,omitempty"`
+	Error              string            `json:"error,omitempty"`
+	ActionMatch        bool              `json:"action_match"`
+	ParamsMatch        bool              `json:"params_match"` // Só tem sentido quando há ExpectedParameters
+	Latency            time.Duration     `json:"latency_ns"`
+	Usage              llm.Usage         `json:"usage"`
+}
+
+// Run é uma execução completa do conjunto com uma versão de prompt. É o formato gravado
+// em disco para servir de base em comparações futuras.
+type Run struct {
+	PromptVersion string     `json:"prompt_version"`
+	Model         string     `json:"model"`
+	Dataset       string     `json:"dataset"`
+	StartedAt     time.Time  `json:"started_at"`
+	Price         *llm.Price `json:"price,omitempty"` // Preço usado no custo, quando conhecido
+	Results       []Result   `json:"results"`
+}
+
+// Evaluate classifica todos os exemplos com a versão de prompt informada, executando até
+// concurrency chamadas ao mesmo tempo. Erros do LLM não interrompem a avaliação: ficam
+// registrados no resultado do exemplo e contam como erro de classificação.
+func Evaluate(ctx context.Context, client llm.Client, promptVersion string, examples []Example, concurrency int) (Run, error) {
+	classifier, err := service.NewIntentClassifier(client, promptVersion)
+	if err != nil {
+		return Run{}, err
+	}
+	promptVersion = classifier.Version()
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	run := Run{PromptVersion: promptVersion, StartedAt: time.Now(), Results: make([]Result, len(examples))}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, ex := range examples {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			run.Results[i] = evaluateExample(ctx, client, promptVersion, ex)
+		}()
+	}
+	wg.Wait()
+	return run, ctx.Err()
+}
+
+func evaluateExample(ctx context.Context, client llm.Client, promptVersion string, ex Example) Result {
+	m := &meter{Client: client}
+	classifier, _ := service.NewIntentClassifier(m, promptVersion) // Versão já validada em Evaluate
+
+	start := time.Now()
+	intent, err := classifier.Classify(ctx, ex.Message, ex.Context, nil)
+	result := Result{
+		ID:                 ex.ID,
+		Message:            ex.Message,
+		ExpectedAction:     ex.Action,
+		ExpectedParameters: ex.Parameters,
+		Action:             intent.Action,
+		Parameters:         intent.Parameters,
+		Latency:            time.Since(start),
+		Usage:              m.usage,
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.ActionMatch = intent.Action == ex.Action
+	result.ParamsMatch = result.ActionMatch && paramsMatch(ex.Parameters, intent.Parameters)
+	return result
+}
+
+// meter soma o uso de tokens das chamadas feitas durante a classificação de um exemplo.
+type meter struct {
+	llm.Client
+	usage llm.Usage
+}
+
+func (m *meter) GenerateContent(ctx context.Context, purpose string, req llm.Request) (llm.Response, error) {
+	resp, err := m.Client.GenerateContent(ctx, purpose, req)
+	m.usage = m.usage.Add(resp.Usage)
+	return resp, err
+}
+
+// paramsMatch compara os parâmetros esperados com os obtidos. Valores são comparados sem
+// diferenciar maiúsculas e espaços nas pontas; "amount" é comparado como número, para que
+// "25,50" e "25.50" sejam iguais.
+func paramsMatch(expected, got map[string]string) bool {
+	for key, want := range expected {
+		value := got[key]
+		if key == "amount" {
+			w, errWant := service.ParseAmount(want)
+			g, errGot := service.ParseAmount(value)
+			if errWant != nil || errGot != nil || math.Abs(w-g) >= 0.005 {
+				return false
+			}
+			continue
+		}
+		if !strings.EqualFold(strings.TrimSpace(want), strings.TrimSpace(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveRun grava a execução em JSON.
+func SaveRun(path string, run Run) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("erro ao gravar resultados: %w", err)
+	}
+	return nil
+}
+
+// LoadRun lê uma execução gravada por SaveRun.
+func LoadRun(path string) (Run, error) {
+	var run Run
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return run, fmt.Errorf("erro ao ler resultados: %w", err)
+	}
+	if err := json.Unmarshal(data, &run); err != nil {
+		return run, fmt.Errorf("resultados inválidos em %s: %w", path, err)
+	}
+	return run, nil
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+	"wally/internal/logging"
+	"wally/internal/version"
+)
+
+// Check é uma verificação de dependência executada pelo /readyz.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Cached evita repetir uma verificação cara (ex: chamadas externas) a cada probe,
+// reaproveitando o último resultado por ttl.
+func Cached(check Check, ttl time.Duration) Check {
+	var (
+		mu        sync.Mutex
+		lastErr   error
+		checkedAt time.Time
+	)
+	return Check{
+		Name: check.Name,
+		Run: func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if !checkedAt.IsZero() && time.Since(checkedAt) < ttl {
+				return lastErr
+			}
+			lastErr = check.Run(ctx)
+			checkedAt = time.Now()
+			return lastErr
+		},
+	}
+}
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// HealthzHandler indica apenas que o processo está vivo (liveness probe).
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// NewReadyzHandler verifica as dependências do Wally (readiness probe). Responde 503 se
+// alguma verificação falhar, com o resultado de cada uma no corpo. O endpoint é público,
+// então o corpo só diz "ok" ou "unavailable"; o erro detalhado, que pode conter URLs e
+// dados internos, vai apenas para os logs.
+func NewReadyzHandler(checks []Check, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		resp := healthResponse{Status: "ok", Checks: make(map[string]string, len(checks))}
+		status := http.StatusOK
+		for _, check := range checks {
+			if err := check.Run(ctx); err != nil {
+				logging.FromContext(r.Context()).Warn("verificação de prontidão falhou",
+					slog.String("check", check.Name), slog.Any("error", err))
+				resp.Checks[check.Name] = "unavailable"
+				resp.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			resp.Checks[check.Name] = "ok"
+		}
+		writeJSON(w, status, resp)
+	}
+}
+
+// VersionHandler retorna as informações de build do binário.
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, version.Get())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -1,11 +1,24 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
-	"wally/internal/service"
+	"time"
+	"wally/internal/domain"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+	"wally/internal/telemetry"
+	"wally/internal/worker"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type WebhookPayload struct {
@@ -23,8 +36,14 @@ type WebhookPayload struct {
 			PushName         string `json:"pushName"`
 			Broadcast        bool   `json:"broadcast"`
 			Message          struct {
-				Conversation       string `json:"conversation"`
-				MessageContextInfo any    `json:"messageContextInfo"`
+				Conversation       string          `json:"conversation"`
+				ImageMessage       json.RawMessage `json:"imageMessage,omitempty"`
+				AudioMessage       json.RawMessage `json:"audioMessage,omitempty"`
+				MessageContextInfo any             `json:"messageContextInfo"`
+
+				ButtonsResponseMessage     *ButtonsResponse     `json:"buttonsResponseMessage,omitempty"`
+				ListResponseMessage        *ListResponse        `json:"listResponseMessage,omitempty"`
+				TemplateButtonReplyMessage *TemplateButtonReply `json:"templateButtonReplyMessage,omitempty"`
 			} `json:"message"`
 			RemoteJid string `json:"remoteJid"`
 			ID        string `json:"id"`
@@ -32,32 +51,209 @@ type WebhookPayload struct {
 	} `json:"data"`
 }
 
-func WebhookHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Metodo nao permitido", http.StatusMethodNotAllowed)
-		return
-	}
+// ButtonsResponse é a resposta a uma mensagem com botões.
+type ButtonsResponse struct {
+	SelectedButtonID    string `json:"selectedButtonId"`
+	SelectedDisplayText string `json:"selectedDisplayText"`
+}
+
+// ListResponse é a resposta a uma mensagem de lista.
+type ListResponse struct {
+	Title             string `json:"title"`
+	SingleSelectReply struct {
+		SelectedRowID string `json:"selectedRowId"`
+	} `json:"singleSelectReply"`
+}
+
+// TemplateButtonReply é a resposta a um botão de template.
+type TemplateButtonReply struct {
+	SelectedID          string `json:"selectedId"`
+	SelectedDisplayText string `json:"selectedDisplayText"`
+}
+
+// Dispatcher executa o processamento das mensagens fora da requisição HTTP. O contexto
+// entregue ao job é cancelado no desligamento do servidor. Jobs com a mesma chave, o
+// remetente da mensagem, devem ser executados em ordem e um de cada vez.
+type Dispatcher interface {
+	Submit(key string, job worker.Job) bool
+}
+
+// Processor trata cada tipo de mensagem recebida pelo webhook.
+type Processor interface {
+	ProcessMessage(ctx context.Context, number string, message string, name string)
+	ProcessChoice(ctx context.Context, number string, choiceID string, name string)
+	ProcessImageMessage(ctx context.Context, number string, name string, media domain.MediaMessage)
+	ProcessAudioMessage(ctx context.Context, number string, name string, media domain.MediaMessage)
+}
+
+// NewWebhookHandler cria o handler do webhook da WaSenderAPI. O corpo da requisição é
+// limitado a maxBodyBytes e o processamento da mensagem é entregue ao dispatcher, para
+// que o webhook responda rapidamente e o trabalho possa ser drenado no desligamento.
+// Cada mensagem tem no máximo messageTimeout para ser processada pelo processor.
+func NewWebhookHandler(processor Processor, dispatcher Dispatcher, maxBodyBytes int64, messageTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// O span do webhook não usa o contexto da requisição como pai de cancelamento: o
+		// processamento continua no dispatcher depois que a resposta HTTP é enviada.
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(r.Header))
+		ctx, span := telemetry.Start(ctx, "webhook")
+		var spanErr error
+		defer func() { telemetry.End(span, spanErr) }()
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Metodo nao permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		bodyBytes, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			spanErr = err
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, "Payload muito grande", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Erro ao ler body", http.StatusInternalServerError)
+			return
+		}
+
+		var payload WebhookPayload
+		if err := json.Unmarshal(bodyBytes, &payload); err != nil {
+			spanErr = err
+			http.Error(w, "Erro ao decodificar a mensagem", http.StatusBadRequest)
+			return
+		}
+
+		// O ID da mensagem identifica todos os logs do processamento, do handler ao envio da resposta.
+		correlationID := payload.Data.Messages.Key.ID
+		if correlationID == "" {
+			correlationID = logging.NewCorrelationID()
+		}
+		ctx = logging.WithCorrelationID(ctx, correlationID)
+
+		kind, job, err := buildJob(processor, payload)
+		metrics.WebhooksReceived.WithLabelValues(eventLabel(payload.Event), kind).Inc()
+		span.SetAttributes(
+			telemetry.MessageIDKey.String(correlationID),
+			attribute.String("wally.event", payload.Event),
+			attribute.String("wally.kind", kind),
+		)
+		logging.FromContext(ctx).Debug("webhook recebido", slog.String("event", payload.Event), slog.String("kind", kind))
+		if err != nil {
+			spanErr = err
+			http.Error(w, "Erro ao decodificar a mídia", http.StatusBadRequest)
+			return
+		}
+		if job == nil {
+			return
+		}
 
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Erro ao ler body", http.StatusInternalServerError)
-		return
+		spanContext := span.SpanContext()
+		processMessage := func(workerCtx context.Context) {
+			// O job herda o cancelamento do worker e os valores (correlação e trace) do webhook.
+			ctx := logging.WithCorrelationID(workerCtx, correlationID)
+			ctx = trace.ContextWithSpanContext(ctx, spanContext)
+			ctx, cancel := context.WithTimeout(ctx, messageTimeout)
+			defer cancel()
+			job(ctx)
+		}
+
+		if !dispatcher.Submit(payload.Data.Messages.Key.RemoteJid, processMessage) {
+			spanErr = errors.New("fila de processamento cheia")
+			http.Error(w, "Servidor ocupado", http.StatusServiceUnavailable)
+			return
+		}
 	}
+}
 
-	var payload WebhookPayload
-	if err := json.Unmarshal(bodyBytes, &payload); err != nil {
-		http.Error(w, "Erro ao decodificar a mensagem", http.StatusBadRequest)
-		return
+// knownEvents são os eventos da WaSenderAPI usados como rótulo das métricas.
+var knownEvents = map[string]bool{
+	"messages.upsert":            true,
+	"messages.received":          true,
+	"messages.personal.received": true,
+	"messages.group.received":    true,
+	"messages.update":            true,
+	"messages.delete":            true,
+	"message.sent":               true,
+	"session.status":             true,
+	"qrcode.updated":             true,
+	"contacts.upsert":            true,
+	"chats.upsert":               true,
+	"groups.upsert":              true,
+	"call":                       true,
+	"poll.results":               true,
+}
+
+// eventLabel limita o rótulo das métricas aos eventos conhecidos: o corpo do webhook não é
+// autenticado, e cada valor novo criaria uma série no Prometheus.
+func eventLabel(event string) string {
+	if knownEvents[event] {
+		return event
 	}
+	return "other"
+}
 
+// buildJob traduz o payload no processamento correspondente, junto com o tipo da mensagem.
+// O job é nil quando não há nada a fazer.
+func buildJob(processor Processor, payload WebhookPayload) (string, worker.Job, error) {
 	msg := payload.Data.Messages
 
 	if msg.Key.FromMe {
-		return
+		return "from_me", nil, nil
 	}
 	name := msg.PushName
 	number := strings.Replace(msg.Key.RemoteJid, "@s.whatsapp.net", "", 1)
 	text := msg.Message.Conversation
 
-	service.ProcessMessage(number, text, name)
+	if choiceID := selectedChoice(msg.Message.ButtonsResponseMessage, msg.Message.ListResponseMessage, msg.Message.TemplateButtonReplyMessage); choiceID != "" {
+		return "choice", func(ctx context.Context) { processor.ProcessChoice(ctx, number, choiceID, name) }, nil
+	}
+
+	if len(msg.Message.ImageMessage) > 0 {
+		media, err := parseMedia(msg.Key.ID, "imageMessage", msg.Message.ImageMessage)
+		if err != nil {
+			return "image", nil, err
+		}
+		return "image", func(ctx context.Context) { processor.ProcessImageMessage(ctx, number, name, media) }, nil
+	}
+
+	if len(msg.Message.AudioMessage) > 0 {
+		media, err := parseMedia(msg.Key.ID, "audioMessage", msg.Message.AudioMessage)
+		if err != nil {
+			return "audio", nil, err
+		}
+		return "audio", func(ctx context.Context) { processor.ProcessAudioMessage(ctx, number, name, media) }, nil
+	}
+
+	return "text", func(ctx context.Context) { processor.ProcessMessage(ctx, number, text, name) }, nil
+}
+
+// parseMedia extrai os metadados de uma mídia do payload, preservando o objeto original.
+func parseMedia(messageID string, mediaType string, raw json.RawMessage) (domain.MediaMessage, error) {
+	var info struct {
+		Mimetype string `json:"mimetype"`
+		Caption  string `json:"caption"`
+	}
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return domain.MediaMessage{}, err
+	}
+	return domain.MediaMessage{
+		MessageID: messageID,
+		Type:      mediaType,
+		MimeType:  info.Mimetype,
+		Caption:   info.Caption,
+		Raw:       raw,
+	}, nil
+}
+
+// selectedChoice retorna o ID da opção escolhida em uma resposta interativa, se houver.
+func selectedChoice(buttons *ButtonsResponse, list *ListResponse, template *TemplateButtonReply) string {
+	switch {
+	case buttons != nil:
+		return buttons.SelectedButtonID
+	case list != nil:
+		return list.SingleSelectReply.SelectedRowID
+	case template != nil:
+		return template.SelectedID
+	}
+	return ""
 }
@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+)
+
+// BreakerOptions controla quando o circuito abre e por quanto tempo fica aberto.
+type BreakerOptions struct {
+	Threshold int           // Falhas seguidas de cota ou indisponibilidade que abrem o circuito
+	Cooldown  time.Duration // Tempo aberto antes de deixar passar uma chamada de teste
+}
+
+// DefaultBreakerOptions abre o circuito após cinco falhas seguidas, por 30 segundos.
+var DefaultBreakerOptions = BreakerOptions{Threshold: 5, Cooldown: 30 * time.Second}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// Breaker é um circuit breaker em volta do cliente. Com o provedor falhando seguidamente,
+// as chamadas falham na hora com ErrCircuitOpen, sem esperar timeouts, e o bot segue pelo
+// caminho sem IA. Passado o Cooldown, uma única chamada de teste decide se o circuito fecha.
+type Breaker struct {
+	client Client
+	name   string // Identifica o circuito nos logs e métricas (ex: o modelo)
+	opts   BreakerOptions
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool // Há uma chamada de teste em andamento
+}
+
+// NewBreaker envolve o cliente com um circuit breaker identificado por name.
+func NewBreaker(client Client, name string, opts BreakerOptions) *Breaker {
+	metrics.LLMCircuitOpen.WithLabelValues(name).Set(0)
+	return &Breaker{client: client, name: name, opts: opts}
+}
+
+func (b *Breaker) GenerateContent(ctx context.Context, purpose string, req Request) (Response, error) {
+	if !b.allow(time.Now()) {
+		metrics.LLMErrors.WithLabelValues(purpose, "circuit_open").Inc()
+		return Response{}, fmt.Errorf("%w (%s)", ErrCircuitOpen, b.name)
+	}
+	resp, err := b.client.GenerateContent(ctx, purpose, req)
+	b.record(ctx, err, time.Now())
+	return resp, err
+}
+
+func (b *Breaker) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx)
+}
+
+// allow informa se a chamada pode ser feita, passando o circuito aberto para meio aberto
+// depois do Cooldown.
+func (b *Breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.opts.Cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record atualiza o circuito com o resultado da chamada. Só falhas de cota e de
+// indisponibilidade contam; um erro da própria requisição mostra que o provedor respondeu.
+func (b *Breaker) record(ctx context.Context, err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+
+	providerFailure := errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrProviderUnavailable)
+	switch {
+	case ctx.Err() != nil:
+		// A chamada foi cancelada por quem a fez; não diz nada sobre o provedor.
+	case providerFailure:
+		b.failures++
+		if b.state == breakerHalfOpen || b.failures >= b.opts.Threshold {
+			if b.state != breakerOpen {
+				logging.FromContext(ctx).Warn("circuito do LLM aberto",
+					slog.String("circuit", b.name),
+					slog.Int("failures", b.failures),
+					slog.Duration("cooldown", b.opts.Cooldown),
+					slog.Any("error", err))
+				metrics.LLMCircuitOpen.WithLabelValues(b.name).Set(1)
+			}
+			b.state = breakerOpen
+			b.openedAt = now
+		}
+	default:
+		if b.state != breakerClosed {
+			logging.FromContext(ctx).Info("circuito do LLM fechado", slog.String("circuit", b.name))
+			metrics.LLMCircuitOpen.WithLabelValues(b.name).Set(0)
+		}
+		b.state = breakerClosed
+		b.failures = 0
+	}
+}
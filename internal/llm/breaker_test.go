@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	var fail, block atomic.Bool
+	release := make(chan struct{})
+	client := &fakeClient{fn: func(ctx context.Context, call int) (Response, error) {
+		if block.Load() {
+			<-release
+		}
+		if fail.Load() {
+			return Response{}, &StatusError{StatusCode: 503}
+		}
+		return Response{Text: "ok"}, nil
+	}}
+	cooldown := 20 * time.Millisecond
+	breaker := NewBreaker(client, "test", BreakerOptions{Threshold: 2, Cooldown: cooldown})
+	call := func() error {
+		_, err := breaker.GenerateContent(context.Background(), "intent", Request{})
+		return err
+	}
+
+	// Fechado -> aberto após Threshold falhas seguidas.
+	fail.Store(true)
+	call()
+	call()
+	calls := client.calls.Load()
+	if err := call(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("esperava circuito aberto, obtive %v", err)
+	}
+	if client.calls.Load() != calls {
+		t.Fatal("com o circuito aberto, o cliente não deveria ser chamado")
+	}
+
+	// Aberto -> meio aberto após o Cooldown; a chamada de teste falha e o circuito reabre.
+	time.Sleep(cooldown + 5*time.Millisecond)
+	if err := call(); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("esperava a falha da chamada de teste, obtive %v", err)
+	}
+	if err := call(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("a falha da chamada de teste deveria reabrir o circuito, obtive %v", err)
+	}
+
+	// Meio aberto: só uma chamada de teste por vez; o sucesso fecha o circuito.
+	time.Sleep(cooldown + 5*time.Millisecond)
+	fail.Store(false)
+	block.Store(true)
+	calls = client.calls.Load()
+	probe := make(chan error)
+	go func() { probe <- call() }()
+	for client.calls.Load() == calls {
+		time.Sleep(time.Millisecond)
+	}
+	if err := call(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("com a chamada de teste em andamento, esperava ErrCircuitOpen, obtive %v", err)
+	}
+	close(release)
+	if err := <-probe; err != nil {
+		t.Fatalf("chamada de teste: %v", err)
+	}
+	block.Store(false)
+	for range 3 {
+		if err := call(); err != nil {
+			t.Fatalf("o circuito deveria estar fechado: %v", err)
+		}
+	}
+}
+
+// Erros da própria requisição mostram que o provedor respondeu e não abrem o circuito.
+func TestBreakerIgnoresRequestErrors(t *testing.T) {
+	client := failing(3, &StatusError{StatusCode: 400})
+	breaker := NewBreaker(client, "test", BreakerOptions{Threshold: 1, Cooldown: time.Hour})
+	for range 3 {
+		breaker.GenerateContent(context.Background(), "intent", Request{})
+	}
+	if _, err := breaker.GenerateContent(context.Background(), "intent", Request{}); err != nil {
+		t.Errorf("erros 4xx não deveriam abrir o circuito: %v", err)
+	}
+}
+
+// Uma chamada cancelada por quem a fez não conta como falha do provedor.
+func TestBreakerIgnoresCancelledCalls(t *testing.T) {
+	client := failing(10, ErrProviderUnavailable)
+	breaker := NewBreaker(client, "test", BreakerOptions{Threshold: 1, Cooldown: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	breaker.GenerateContent(ctx, "intent", Request{})
+	if _, err := breaker.GenerateContent(context.Background(), "intent", Request{}); errors.Is(err, ErrCircuitOpen) {
+		t.Error("o cancelamento não deveria abrir o circuito")
+	}
+}
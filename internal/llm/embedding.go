@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"wally/internal/metrics"
+	"wally/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Embedder converte textos em vetores cuja proximidade (cosseno) reflete o significado.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// GeminiEmbedder gera embeddings com a API embedContent da Gemini.
+type GeminiEmbedder struct {
+	apiKey     string
+	model      string
+	timeout    time.Duration
+	httpClient *http.Client
+}
+
+// NewGeminiEmbedder cria um embedder para o modelo informado (ex: "text-embedding-004").
+func NewGeminiEmbedder(apiKey string, model string, timeout time.Duration) *GeminiEmbedder {
+	return &GeminiEmbedder{apiKey: apiKey, model: model, timeout: timeout, httpClient: &http.Client{}}
+}
+
+type geminiEmbedRequest struct {
+	Model    string  `json:"model"`
+	Content  Content `json:"content"`
+	TaskType string  `json:"taskType"`
+}
+
+type geminiEmbedResponse struct {
+	Embedding struct {
+		Values []float32 `json:"values"`
+	} `json:"embedding"`
+}
+
+// Embed gera o embedding do texto. Mensagens guardadas e mensagens novas são comparadas
+// entre si, por isso a tarefa é sempre SEMANTIC_SIMILARITY.
+func (g *GeminiEmbedder) Embed(ctx context.Context, text string) (_ []float32, err error) {
+	const purpose = "embedding"
+	ctx, span := telemetry.Start(ctx, "llm.embed_content",
+		attribute.String("gen_ai.system", "gemini"),
+		attribute.String("gen_ai.request.model", g.model))
+	defer func() { telemetry.End(span, err) }()
+
+	apiURL := geminiBaseURL + g.model + ":embedContent"
+	payloadBytes, err := json.Marshal(geminiEmbedRequest{
+		Model:    "models/" + g.model,
+		Content:  Content{Parts: []Part{{Text: text}}},
+		TaskType: "SEMANTIC_SIMILARITY",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer marshal do payload de embedding: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	req, err := newGeminiRequest(ctx, "POST", apiURL, g.apiKey, bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição de embedding: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := g.httpClient.Do(req)
+	metrics.LLMRequestDuration.WithLabelValues(purpose).Observe(metrics.Since(start))
+	if err != nil {
+		metrics.LLMRequests.WithLabelValues(purpose, "error").Inc()
+		metrics.LLMErrors.WithLabelValues(purpose, "transport").Inc()
+		return nil, fmt.Errorf("erro ao enviar requisição de embedding: %w", err)
+	}
+	defer resp.Body.Close()
+	metrics.LLMRequests.WithLabelValues(purpose, strconv.Itoa(resp.StatusCode)).Inc()
+
+	if resp.StatusCode != http.StatusOK {
+		metrics.LLMErrors.WithLabelValues(purpose, "status").Inc()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API Gemini retornou status não OK para embedding: %s. Detalhes: %s", resp.Status, string(bodyBytes))
+	}
+
+	var embedResp geminiEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		metrics.LLMErrors.WithLabelValues(purpose, "decode").Inc()
+		return nil, fmt.Errorf("erro ao decodificar embedding: %w", err)
+	}
+	if len(embedResp.Embedding.Values) == 0 {
+		metrics.LLMErrors.WithLabelValues(purpose, "empty_response").Inc()
+		return nil, errors.New("embedding vazio na resposta da Gemini")
+	}
+	return embedResp.Embedding.Values, nil
+}
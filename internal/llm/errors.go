@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Categorias de erro do provedor. Os erros retornados pelos clientes as envolvem, para que
+// os chamadores decidam com errors.Is se vale tentar de novo, usar outro caminho ou avisar
+// o usuário.
+var (
+	// ErrQuotaExceeded indica que a cota ou o limite de requisições do provedor acabou (429).
+	ErrQuotaExceeded = errors.New("cota do provedor de IA excedida")
+	// ErrProviderUnavailable indica falha do provedor (5xx) ou de rede; costuma ser passageira.
+	ErrProviderUnavailable = errors.New("provedor de IA indisponível")
+	// ErrBlocked indica que o modelo recusou a mensagem ou a resposta pelos filtros de segurança.
+	ErrBlocked = errors.New("conteúdo bloqueado pelos filtros de segurança do modelo")
+	// ErrCircuitOpen indica que o circuito está aberto e a chamada nem foi feita.
+	ErrCircuitOpen = errors.New("circuito do provedor de IA aberto")
+)
+
+// Unavailable informa se o erro indica que o provedor não está atendendo (cota, falha do
+// provedor ou circuito aberto), caso em que o chamador deve seguir por um caminho sem IA.
+func Unavailable(err error) bool {
+	return errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrProviderUnavailable) || errors.Is(err, ErrCircuitOpen)
+}
+
+// StatusError é uma resposta HTTP de erro do provedor.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string        // Pode ecoar o prompt; fica fora de Error() e só vai ao log como dado sensível
+	RetryAfter time.Duration // Espera pedida pelo provedor, quando informada
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API Gemini retornou status não OK: %s", e.Status)
+}
+
+// Unwrap associa o status à categoria de erro: 429 é cota, 5xx é indisponibilidade. Os
+// demais (400, 403…) são erros da requisição e não adianta repeti-los.
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrQuotaExceeded
+	case e.StatusCode >= 500:
+		return ErrProviderUnavailable
+	default:
+		return nil
+	}
+}
+
+// retryAfter lê a espera pedida pelo provedor no cabeçalho Retry-After (segundos ou data
+// HTTP) ou, na falta dele, no RetryInfo do corpo de erro da API do Google.
+func retryAfter(header http.Header, body []byte, now time.Time) time.Duration {
+	if v := header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(v); err == nil && at.After(now) {
+			return at.Sub(now)
+		}
+	}
+
+	var errorBody struct {
+		Error struct {
+			Details []struct {
+				Type       string `json:"@type"`
+				RetryDelay string `json:"retryDelay"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errorBody) != nil {
+		return 0
+	}
+	for _, detail := range errorBody.Error.Details {
+		if detail.Type != "type.googleapis.com/google.rpc.RetryInfo" {
+			continue
+		}
+		if delay, err := time.ParseDuration(detail.RetryDelay); err == nil && delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
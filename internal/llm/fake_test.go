@@ -0,0 +1,30 @@
+package llm
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// fakeClient responde com fn, que recebe o número da chamada (a partir de 1).
+type fakeClient struct {
+	calls atomic.Int32
+	fn    func(ctx context.Context, call int) (Response, error)
+}
+
+func (f *fakeClient) GenerateContent(ctx context.Context, purpose string, req Request) (Response, error) {
+	return f.fn(ctx, int(f.calls.Add(1)))
+}
+
+func (f *fakeClient) Ping(ctx context.Context) error {
+	return nil
+}
+
+// failing falha as primeiras n chamadas com err e depois responde "ok".
+func failing(n int, err error) *fakeClient {
+	return &fakeClient{fn: func(ctx context.Context, call int) (Response, error) {
+		if call <= n {
+			return Response{}, err
+		}
+		return Response{Text: "ok"}, nil
+	}}
+}
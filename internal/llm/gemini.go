@@ -0,0 +1,218 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+	"wally/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta/models/"
+
+// Estruturas da resposta da API Gemini
+type geminiResponsePart struct {
+	Text string `json:"text"`
+}
+
+type geminiResponseContent struct {
+	Parts []geminiResponsePart `json:"parts"`
+	Role  string               `json:"role"`
+}
+
+type geminiCandidate struct {
+	Content      geminiResponseContent `json:"content"`
+	FinishReason string                `json:"finishReason"`
+	Index        int                   `json:"index"`
+}
+
+type geminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+type geminiPromptFeedback struct {
+	BlockReason string `json:"blockReason"`
+}
+
+type geminiAPIResponse struct {
+	Candidates     []geminiCandidate     `json:"candidates"`
+	PromptFeedback *geminiPromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  geminiUsageMetadata   `json:"usageMetadata"`
+	ModelVersion   string                `json:"modelVersion"`
+}
+
+// blockedFinishReasons são os motivos de término em que a resposta foi barrada pelos
+// filtros de segurança.
+var blockedFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"PROHIBITED_CONTENT": true,
+	"BLOCKLIST":          true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+// blockReason retorna o motivo do bloqueio da mensagem ou da resposta, se houve.
+func (r geminiAPIResponse) blockReason() string {
+	if r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+		return r.PromptFeedback.BlockReason
+	}
+	if len(r.Candidates) > 0 && blockedFinishReasons[r.Candidates[0].FinishReason] {
+		return r.Candidates[0].FinishReason
+	}
+	return ""
+}
+
+// Gemini é o cliente da API REST da Gemini.
+type Gemini struct {
+	apiKey     string
+	model      string
+	timeout    time.Duration
+	httpClient *http.Client // Os prazos vêm do contexto de cada requisição
+}
+
+// NewGemini cria um cliente para o modelo informado. Cada chamada é limitada a timeout.
+func NewGemini(apiKey string, model string, timeout time.Duration) *Gemini {
+	return &Gemini{apiKey: apiKey, model: model, timeout: timeout, httpClient: &http.Client{}}
+}
+
+// GenerateContent envia o payload para o modelo Gemini e retorna o texto do primeiro candidato.
+func (g *Gemini) GenerateContent(ctx context.Context, purpose string, requestPayload Request) (_ Response, err error) {
+	ctx, span := telemetry.Start(ctx, "llm.generate_content",
+		attribute.String("gen_ai.system", "gemini"),
+		attribute.String("gen_ai.request.model", g.model),
+		attribute.String("wally.purpose", purpose))
+	defer func() { telemetry.End(span, err) }()
+
+	apiURL := geminiBaseURL + g.model + ":generateContent"
+
+	payloadBytes, err := json.Marshal(requestPayload)
+	if err != nil {
+		return Response{}, fmt.Errorf("erro ao fazer marshal do payload da Gemini: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	req, err := newGeminiRequest(ctx, "POST", apiURL, g.apiKey, bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return Response{}, fmt.Errorf("erro ao criar requisição para Gemini: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := g.httpClient.Do(req)
+	metrics.LLMRequestDuration.WithLabelValues(purpose).Observe(metrics.Since(start))
+	if err != nil {
+		metrics.LLMRequests.WithLabelValues(purpose, "error").Inc()
+		metrics.LLMErrors.WithLabelValues(purpose, "transport").Inc()
+		return Response{}, fmt.Errorf("erro ao enviar requisição para Gemini: %w: %w", ErrProviderUnavailable, err)
+	}
+	defer resp.Body.Close()
+	metrics.LLMRequests.WithLabelValues(purpose, strconv.Itoa(resp.StatusCode)).Inc()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		statusErr := &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(bodyBytes),
+			RetryAfter: retryAfter(resp.Header, bodyBytes, time.Now()),
+		}
+		switch {
+		case errors.Is(statusErr, ErrQuotaExceeded):
+			metrics.LLMErrors.WithLabelValues(purpose, "quota").Inc()
+		case errors.Is(statusErr, ErrProviderUnavailable):
+			metrics.LLMErrors.WithLabelValues(purpose, "server").Inc()
+		default:
+			metrics.LLMErrors.WithLabelValues(purpose, "status").Inc()
+		}
+		logging.FromContext(ctx).Error("erro da API Gemini",
+			slog.String("status", resp.Status),
+			slog.Duration("retry_after", statusErr.RetryAfter),
+			logging.Sensitive("body", statusErr.Body))
+		return Response{}, statusErr
+	}
+
+	var geminiAPIResp geminiAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&geminiAPIResp); err != nil {
+		metrics.LLMErrors.WithLabelValues(purpose, "decode").Inc()
+		return Response{}, fmt.Errorf("erro ao decodificar resposta da Gemini: %w", err)
+	}
+
+	if reason := geminiAPIResp.blockReason(); reason != "" {
+		metrics.LLMErrors.WithLabelValues(purpose, "blocked").Inc()
+		logging.FromContext(ctx).Warn("resposta da Gemini bloqueada pelos filtros de segurança", slog.String("reason", reason))
+		return Response{}, fmt.Errorf("%w: %s", ErrBlocked, reason)
+	}
+
+	if len(geminiAPIResp.Candidates) == 0 || len(geminiAPIResp.Candidates[0].Content.Parts) == 0 {
+		metrics.LLMErrors.WithLabelValues(purpose, "empty_response").Inc()
+		logging.FromContext(ctx).Warn("resposta da Gemini não contém candidatos ou partes válidas",
+			logging.Sensitive("response", geminiAPIResp))
+		return Response{}, ErrEmptyResponse
+	}
+
+	usage := geminiAPIResp.UsageMetadata
+	return Response{
+		Text:      geminiAPIResp.Candidates[0].Content.Parts[0].Text,
+		Model:     geminiAPIResp.ModelVersion,
+		Truncated: geminiAPIResp.Candidates[0].FinishReason == "MAX_TOKENS",
+		Usage: Usage{
+			PromptTokens: usage.PromptTokenCount,
+			OutputTokens: usage.CandidatesTokenCount + usage.ThoughtsTokenCount,
+			TotalTokens:  usage.TotalTokenCount,
+		},
+	}, nil
+}
+
+// Ping consulta os metadados do modelo configurado.
+func (g *Gemini) Ping(ctx context.Context) error {
+	apiURL := geminiBaseURL + g.model
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	req, err := newGeminiRequest(ctx, "GET", apiURL, g.apiKey, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao criar requisição para Gemini: %w", err)
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Gemini inacessível: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Gemini retornou status %s", resp.Status)
+	}
+	return nil
+}
+
+// newGeminiRequest cria a requisição com a chave no cabeçalho x-goog-api-key. Na URL, a
+// chave apareceria no texto dos erros de transporte (*url.Error), que vão para logs, spans
+// e respostas de health check.
+func newGeminiRequest(ctx context.Context, method string, apiURL string, apiKey string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, apiURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-goog-api-key", apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKey = "AIzaSy-chave-secreta"
+
+// failingTransport falha toda requisição, como uma rede fora do ar, e guarda a última.
+type failingTransport struct {
+	req *http.Request
+}
+
+func (t *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.req = req
+	return nil, errors.New("dial tcp: i/o timeout")
+}
+
+// A chave vai no cabeçalho: erros de transporte citam a URL e são gravados em logs e spans.
+func TestTransportErrorsDoNotLeakKey(t *testing.T) {
+	transport := &failingTransport{}
+	client := &http.Client{Transport: transport}
+	gemini := NewGemini(testKey, "gemini-test", time.Second)
+	gemini.httpClient = client
+	embedder := NewGeminiEmbedder(testKey, "embedding-test", time.Second)
+	embedder.httpClient = client
+
+	calls := map[string]func() error{
+		"GenerateContent": func() error {
+			_, err := gemini.GenerateContent(context.Background(), "intent", Request{})
+			return err
+		},
+		"Ping": func() error {
+			return gemini.Ping(context.Background())
+		},
+		"Embed": func() error {
+			_, err := embedder.Embed(context.Background(), "uber 30")
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			err := call()
+			if err == nil {
+				t.Fatal("esperava erro de transporte")
+			}
+			if strings.Contains(err.Error(), testKey) {
+				t.Errorf("o erro contém a chave: %v", err)
+			}
+			if strings.Contains(transport.req.URL.String(), testKey) {
+				t.Errorf("a URL contém a chave: %s", transport.req.URL)
+			}
+			if got := transport.req.Header.Get("x-goog-api-key"); got != testKey {
+				t.Errorf("cabeçalho x-goog-api-key = %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"time"
+	"wally/internal/metrics"
+)
+
+// Hedged envia a chamada ao cliente principal e, se ele não responder em delay ou falhar
+// por cota ou indisponibilidade, também ao secundário (normalmente outro modelo). Vale a
+// primeira resposta bem-sucedida; a outra chamada é cancelada.
+type Hedged struct {
+	primary   Client
+	secondary Client
+	delay     time.Duration // 0 só recorre ao secundário quando o principal falha
+}
+
+// NewHedged cria o cliente com o secundário como reserva do principal.
+func NewHedged(primary Client, secondary Client, delay time.Duration) *Hedged {
+	return &Hedged{primary: primary, secondary: secondary, delay: delay}
+}
+
+type hedgeResult struct {
+	resp  Response
+	err   error
+	hedge bool
+}
+
+func (h *Hedged) GenerateContent(ctx context.Context, purpose string, req Request) (Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// O canal comporta as duas respostas, para que a chamada perdedora não fique bloqueada.
+	results := make(chan hedgeResult, 2)
+	launch := func(client Client, hedge bool) {
+		go func() {
+			resp, err := client.GenerateContent(ctx, purpose, req)
+			results <- hedgeResult{resp: resp, err: err, hedge: hedge}
+		}()
+	}
+	launch(h.primary, false)
+	pending, hedged := 1, false
+	hedge := func(reason string) {
+		hedged = true
+		pending++
+		metrics.LLMHedges.WithLabelValues(purpose, reason).Inc()
+		launch(h.secondary, true)
+	}
+
+	var timeout <-chan time.Time
+	if h.delay > 0 {
+		timer := time.NewTimer(h.delay)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var errs []error
+	for {
+		select {
+		case <-timeout:
+			if !hedged {
+				hedge("slow")
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if result.hedge {
+					metrics.LLMHedges.WithLabelValues(purpose, "won").Inc()
+				}
+				return result.resp, nil
+			}
+			errs = append(errs, result.err)
+			if !hedged && Unavailable(result.err) && ctx.Err() == nil {
+				hedge("failed")
+				continue
+			}
+			if pending == 0 {
+				return Response{}, errors.Join(errs...)
+			}
+		}
+	}
+}
+
+// Ping verifica só o cliente principal: o secundário é uma reserva.
+func (h *Hedged) Ping(ctx context.Context) error {
+	return h.primary.Ping(ctx)
+}
@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// answering responde text depois de delay ou avisa em cancelled se o contexto for cancelado
+// antes.
+func answering(text string, delay time.Duration, cancelled chan<- struct{}) *fakeClient {
+	return &fakeClient{fn: func(ctx context.Context, call int) (Response, error) {
+		select {
+		case <-time.After(delay):
+			return Response{Text: text}, nil
+		case <-ctx.Done():
+			if cancelled != nil {
+				close(cancelled)
+			}
+			return Response{}, ctx.Err()
+		}
+	}}
+}
+
+func TestHedgedWinner(t *testing.T) {
+	t.Run("principal rápido", func(t *testing.T) {
+		primary, secondary := answering("principal", 0, nil), answering("secundário", 0, nil)
+		resp, err := NewHedged(primary, secondary, time.Second).GenerateContent(context.Background(), "intent", Request{})
+		if err != nil || resp.Text != "principal" {
+			t.Fatalf("resposta = %+v, %v", resp, err)
+		}
+		if secondary.calls.Load() != 0 {
+			t.Error("o secundário não deveria ser chamado")
+		}
+	})
+
+	t.Run("principal lento perde e é cancelado", func(t *testing.T) {
+		cancelled := make(chan struct{})
+		primary, secondary := answering("principal", time.Hour, cancelled), answering("secundário", 0, nil)
+		resp, err := NewHedged(primary, secondary, 10*time.Millisecond).GenerateContent(context.Background(), "intent", Request{})
+		if err != nil || resp.Text != "secundário" {
+			t.Fatalf("resposta = %+v, %v", resp, err)
+		}
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Error("a chamada perdedora não foi cancelada")
+		}
+	})
+
+	t.Run("secundário lento perde e é cancelado", func(t *testing.T) {
+		cancelled := make(chan struct{})
+		primary, secondary := answering("principal", 30*time.Millisecond, nil), answering("secundário", time.Hour, cancelled)
+		resp, err := NewHedged(primary, secondary, 5*time.Millisecond).GenerateContent(context.Background(), "intent", Request{})
+		if err != nil || resp.Text != "principal" {
+			t.Fatalf("resposta = %+v, %v", resp, err)
+		}
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Error("a chamada perdedora não foi cancelada")
+		}
+	})
+
+	t.Run("principal indisponível", func(t *testing.T) {
+		primary, secondary := failing(1, &StatusError{StatusCode: 503}), answering("secundário", 0, nil)
+		resp, err := NewHedged(primary, secondary, time.Hour).GenerateContent(context.Background(), "intent", Request{})
+		if err != nil || resp.Text != "secundário" {
+			t.Fatalf("resposta = %+v, %v", resp, err)
+		}
+	})
+
+	t.Run("erro da requisição não aciona o secundário", func(t *testing.T) {
+		primary, secondary := failing(1, ErrBlocked), answering("secundário", 0, nil)
+		_, err := NewHedged(primary, secondary, time.Hour).GenerateContent(context.Background(), "intent", Request{})
+		if !errors.Is(err, ErrBlocked) || secondary.calls.Load() != 0 {
+			t.Errorf("erro = %v, chamadas ao secundário = %d", err, secondary.calls.Load())
+		}
+	})
+
+	t.Run("os dois falham", func(t *testing.T) {
+		primary, secondary := failing(1, ErrProviderUnavailable), failing(1, ErrQuotaExceeded)
+		_, err := NewHedged(primary, secondary, time.Hour).GenerateContent(context.Background(), "intent", Request{})
+		if !errors.Is(err, ErrProviderUnavailable) || !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("o erro deveria reunir as duas falhas: %v", err)
+		}
+	})
+}
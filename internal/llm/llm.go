@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"errors"
+)
+
+// Client gera conteúdo a partir de um modelo de linguagem.
+type Client interface {
+	// GenerateContent envia a requisição ao modelo e retorna o texto do primeiro candidato.
+	// purpose identifica a finalidade da chamada nas métricas (ex: "intent", "receipt").
+	GenerateContent(ctx context.Context, purpose string, req Request) (Response, error)
+	// Ping verifica se o provedor está acessível e a credencial é válida.
+	Ping(ctx context.Context) error
+}
+
+// ErrEmptyResponse indica que o modelo respondeu sem nenhum candidato utilizável.
+var ErrEmptyResponse = errors.New("resposta do modelo malformada ou vazia")
+
+// Response é o resultado de uma chamada ao modelo.
+type Response struct {
+	Text  string
+	Model string // Versão do modelo que respondeu, quando informada pelo provedor
+	Usage Usage
+	// Truncated indica que o texto foi cortado ao atingir GenerationConfig.MaxOutputTokens.
+	Truncated bool
+}
+
+// Usage é a contagem de tokens de uma chamada.
+type Usage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	OutputTokens int `json:"output_tokens"` // Inclui os tokens de raciocínio, cobrados como saída
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// Add soma as contagens de outra chamada.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens: u.PromptTokens + other.PromptTokens,
+		OutputTokens: u.OutputTokens + other.OutputTokens,
+		TotalTokens:  u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// Estruturas da requisição, no formato da API generateContent da Gemini.
+type Part struct {
+	Text       string      `json:"text,omitempty"`
+	InlineData *InlineData `json:"inlineData,omitempty"`
+}
+
+// InlineData carrega arquivos binários (ex: imagens) enviados ao modelo.
+type InlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // Conteúdo em base64
+}
+
+type Content struct {
+	Parts []Part `json:"parts"`
+	Role  string `json:"role,omitempty"`
+}
+
+type Request struct {
+	// SystemInstruction leva as instruções do sistema separadas do conteúdo do usuário, que
+	// o modelo não deve tratar como instruções.
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"`
+	Contents          []Content         `json:"contents"`
+	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type GenerationConfig struct {
+	// ResponseMIMEType "application/json" força uma resposta em JSON; vazio gera texto livre.
+	ResponseMIMEType string `json:"responseMimeType,omitempty"`
+	// MaxOutputTokens limita o tamanho da resposta; 0 usa o limite do modelo.
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+}
@@ -0,0 +1,45 @@
+package llm
+
+import "strings"
+
+// Price é o custo de um modelo em USD por milhão de tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// prices são valores de referência da tabela pública da Gemini (contexto até 128k tokens).
+// Podem ficar desatualizados; quem precisar de um valor exato deve informar o preço.
+var prices = map[string]Price{
+	"gemini-1.5-flash":      {Input: 0.075, Output: 0.30},
+	"gemini-1.5-flash-8b":   {Input: 0.0375, Output: 0.15},
+	"gemini-1.5-pro":        {Input: 1.25, Output: 5.00},
+	"gemini-2.0-flash":      {Input: 0.10, Output: 0.40},
+	"gemini-2.0-flash-lite": {Input: 0.075, Output: 0.30},
+	"gemini-2.5-flash":      {Input: 0.30, Output: 2.50},
+	"gemini-2.5-flash-lite": {Input: 0.10, Output: 0.40},
+	"gemini-2.5-pro":        {Input: 1.25, Output: 10.00},
+}
+
+// PriceFor retorna o preço de referência do modelo. Aliases como "-latest" e versões
+// fixadas ("-001") usam o preço do modelo base.
+func PriceFor(model string) (Price, bool) {
+	model = strings.TrimPrefix(model, "models/")
+	model = strings.TrimSuffix(model, "-latest")
+	for candidate := model; candidate != ""; {
+		if price, ok := prices[candidate]; ok {
+			return price, true
+		}
+		i := strings.LastIndex(candidate, "-")
+		if i < 0 {
+			break
+		}
+		candidate = candidate[:i]
+	}
+	return Price{}, false
+}
+
+// Cost calcula o custo em USD do uso informado.
+func (p Price) Cost(u Usage) float64 {
+	return (float64(u.PromptTokens)*p.Input + float64(u.OutputTokens)*p.Output) / 1_000_000
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+)
+
+// RetryOptions controla as novas tentativas de uma chamada que falhou por um erro passageiro.
+type RetryOptions struct {
+	MaxAttempts int           // Tentativas no total, incluindo a primeira
+	BaseDelay   time.Duration // Espera antes da segunda tentativa; dobra a cada nova tentativa
+	MaxDelay    time.Duration // Espera máxima entre tentativas, inclusive a pedida pelo provedor
+}
+
+// DefaultRetryOptions tenta até três vezes, esperando cerca de 0,5s e 1s entre as tentativas.
+var DefaultRetryOptions = RetryOptions{MaxAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 8 * time.Second}
+
+// Retrying repete as chamadas que falham por cota ou indisponibilidade do provedor, com
+// espera exponencial e jitter, respeitando o Retry-After informado pelo provedor.
+type Retrying struct {
+	client Client
+	opts   RetryOptions
+}
+
+// NewRetrying envolve o cliente com novas tentativas.
+func NewRetrying(client Client, opts RetryOptions) *Retrying {
+	return &Retrying{client: client, opts: opts}
+}
+
+func (r *Retrying) GenerateContent(ctx context.Context, purpose string, req Request) (Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := r.client.GenerateContent(ctx, purpose, req)
+		if err == nil || attempt >= r.opts.MaxAttempts || ctx.Err() != nil {
+			return resp, err
+		}
+		delay, ok := r.delay(attempt, err)
+		if !ok {
+			return resp, err
+		}
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline && time.Until(deadline) < delay {
+			return resp, err
+		}
+
+		metrics.LLMRetries.WithLabelValues(purpose, errorReason(err)).Inc()
+		logging.FromContext(ctx).Warn("repetindo chamada ao LLM",
+			slog.String("purpose", purpose),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("delay", delay),
+			slog.Any("error", err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *Retrying) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx)
+}
+
+// delay calcula a espera antes da próxima tentativa e informa se vale tentar de novo. Só
+// erros de cota e de indisponibilidade são repetidos; se o provedor pedir uma espera maior
+// que MaxDelay, é melhor desistir e seguir por outro caminho.
+func (r *Retrying) delay(attempt int, err error) (time.Duration, bool) {
+	if !errors.Is(err, ErrQuotaExceeded) && !errors.Is(err, ErrProviderUnavailable) {
+		return 0, false
+	}
+
+	// Jitter "igual": metade da espera é fixa e a outra metade aleatória, para que clientes
+	// que falharam juntos não tentem de novo juntos.
+	backoff := min(r.opts.BaseDelay<<(attempt-1), r.opts.MaxDelay)
+	delay := backoff/2 + rand.N(backoff/2+1)
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		if statusErr.RetryAfter > r.opts.MaxDelay {
+			return 0, false
+		}
+		delay = max(delay, statusErr.RetryAfter)
+	}
+	return delay, true
+}
+
+// errorReason resume o erro para os rótulos das métricas.
+func errorReason(err error) string {
+	switch {
+	case errors.Is(err, ErrQuotaExceeded):
+		return "quota"
+	case errors.Is(err, ErrProviderUnavailable):
+		return "server"
+	case errors.Is(err, ErrBlocked):
+		return "blocked"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	default:
+		return "other"
+	}
+}
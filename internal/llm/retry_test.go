@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var fastRetry = RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func TestRetryingRepeatsTransientErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"429 e depois sucesso", &StatusError{StatusCode: 429}, 1, 2, false},
+		{"503 e depois sucesso", &StatusError{StatusCode: 503}, 2, 3, false},
+		{"5xx em todas as tentativas", &StatusError{StatusCode: 500}, 5, 3, true},
+		{"transporte", ErrProviderUnavailable, 1, 2, false},
+		{"400 não é repetido", &StatusError{StatusCode: 400}, 5, 1, true},
+		{"404 não é repetido", &StatusError{StatusCode: 404}, 5, 1, true},
+		{"bloqueio não é repetido", ErrBlocked, 5, 1, true},
+		{"Retry-After acima do máximo", &StatusError{StatusCode: 429, RetryAfter: time.Minute}, 5, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := failing(tt.failures, tt.err)
+			resp, err := NewRetrying(client, fastRetry).GenerateContent(context.Background(), "intent", Request{})
+			if calls := int(client.calls.Load()); calls != tt.wantCalls {
+				t.Errorf("chamadas = %d, esperado %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("erro = %v, esperado %v", err, tt.err)
+				}
+			} else if err != nil || resp.Text != "ok" {
+				t.Errorf("resposta = %+v, %v", resp, err)
+			}
+		})
+	}
+}
+
+func TestRetryingRespectsContext(t *testing.T) {
+	slow := RetryOptions{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+
+	t.Run("prazo menor que a espera", func(t *testing.T) {
+		client := failing(5, ErrProviderUnavailable)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		start := time.Now()
+		_, err := NewRetrying(client, slow).GenerateContent(ctx, "intent", Request{})
+		if !errors.Is(err, ErrProviderUnavailable) || client.calls.Load() != 1 || time.Since(start) > time.Second {
+			t.Errorf("esperava desistir na hora: err=%v chamadas=%d em %s", err, client.calls.Load(), time.Since(start))
+		}
+	})
+
+	t.Run("cancelado durante a espera", func(t *testing.T) {
+		client := failing(5, ErrProviderUnavailable)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := NewRetrying(client, slow).GenerateContent(ctx, "intent", Request{})
+		if !errors.Is(err, ErrProviderUnavailable) || client.calls.Load() != 1 || time.Since(start) > time.Second {
+			t.Errorf("esperava parar ao cancelar: err=%v chamadas=%d em %s", err, client.calls.Load(), time.Since(start))
+		}
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	r := NewRetrying(nil, RetryOptions{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond})
+	for attempt, backoff := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond, 4: 300 * time.Millisecond} {
+		delay, ok := r.delay(attempt, ErrProviderUnavailable)
+		if !ok || delay < backoff/2 || delay > backoff {
+			t.Errorf("tentativa %d: espera %s fora de [%s, %s]", attempt, delay, backoff/2, backoff)
+		}
+	}
+	if delay, ok := r.delay(1, &StatusError{StatusCode: 429, RetryAfter: 250 * time.Millisecond}); !ok || delay < 250*time.Millisecond {
+		t.Errorf("espera %s não respeita o Retry-After", delay)
+	}
+}
@@ -0,0 +1,12 @@
+package llm
+
+import "unicode/utf8"
+
+// charsPerToken é a média aproximada de caracteres por token da Gemini em português.
+const charsPerToken = 4
+
+// EstimateTokens estima quantos tokens o texto ocupa no prompt, sem chamar a API. Serve
+// para orçamentos de contexto, não para cobrança: o valor real vem em Response.Usage.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
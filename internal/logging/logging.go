@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type correlationKey struct{}
+
+// debugMode desativa a redação de dados pessoais. Só deve ser habilitado explicitamente.
+var debugMode bool
+
+// Setup configura o logger padrão (slog) com saída JSON no nível informado.
+// Com debug=true, telefones e textos de mensagens deixam de ser mascarados.
+func Setup(w io.Writer, level string, debug bool) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("nível de log inválido %q: %w", level, err)
+	}
+	debugMode = debug
+
+	logger := slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl}))
+	slog.SetDefault(logger)
+	return nil
+}
+
+// NewCorrelationID gera um identificador aleatório para correlacionar os logs de uma mensagem.
+func NewCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithCorrelationID associa o ID de correlação ao contexto.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID retorna o ID de correlação do contexto, se houver.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// FromContext retorna o logger padrão já anotado com o ID de correlação do contexto.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := CorrelationID(ctx); id != "" {
+		logger = logger.With(slog.String("correlation_id", id))
+	}
+	return logger
+}
+
+// Phone registra um número de telefone mascarado, mantendo só os 4 últimos dígitos.
+func Phone(number string) slog.Attr {
+	return slog.Any("phone", phoneValue(number))
+}
+
+// Sensitive registra um valor que pode conter dados pessoais ou financeiros (textos de
+// mensagens, nomes, valores, respostas da IA). Fora do modo debug, apenas o tamanho é mantido.
+func Sensitive(key string, value any) slog.Attr {
+	return slog.Any(key, sensitiveValue{value: value})
+}
+
+type phoneValue string
+
+func (p phoneValue) LogValue() slog.Value {
+	if debugMode || len(p) <= 4 {
+		return slog.StringValue(string(p))
+	}
+	return slog.StringValue(strings.Repeat("*", len(p)-4) + string(p[len(p)-4:]))
+}
+
+type sensitiveValue struct {
+	value any
+}
+
+func (s sensitiveValue) LogValue() slog.Value {
+	if debugMode {
+		return slog.AnyValue(s.value)
+	}
+	if str, ok := s.value.(string); ok {
+		return slog.StringValue(fmt.Sprintf("[redacted len=%d]", len(str)))
+	}
+	return slog.StringValue("[redacted]")
+}
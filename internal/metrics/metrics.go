@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Métricas do pipeline de mensagens, expostas em /metrics no formato do Prometheus.
+var (
+	WebhooksReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wally",
+		Name:      "webhooks_received_total",
+		Help:      "Webhooks recebidos, por evento da WaSenderAPI e tipo de mensagem.",
+	}, []string{"event", "kind"})
+
+	IntentsDetected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wally",
+		Name:      "intents_detected_total",
+		Help:      "Intenções detectadas pelo classificador, por ação.",
+	}, []string{"action"})
+
+	LLMRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "wally",
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latência das chamadas ao provedor de IA, por finalidade.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30},
+	}, []string{"purpose"})
+
+	LLMRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wally",
+		Name:      "llm_requests_total",
+		Help:      "Chamadas ao provedor de IA, por finalidade e status HTTP (\"error\" para falhas de transporte).",
+	}, []string{"purpose", "status_code"})
+
+	LLMErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wally",
+		Name:      "llm_errors_total",
+		Help:      "Erros nas chamadas ao provedor de IA, por finalidade e motivo.",
+	}, []string{"purpose", "reason"})
+
+	LLMRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wally",
+		Name:      "llm_retries_total",
+		Help:      "Novas tentativas de chamadas ao provedor de IA, por finalidade e motivo da falha.",
+	}, []string{"purpose", "reason"})
+
+	LLMCircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "wally",
+		Name:      "llm_circuit_open",
+		Help:      "1 quando o circuit breaker do provedor de IA está aberto, por circuito (modelo).",
+	}, []string{"circuit"})
+
+	LLMHedges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wally",
+		Name:      "llm_hedges_total",
+		Help:      "Chamadas ao modelo secundário, por finalidade e motivo (slow, failed) e as que ele venceu (won).",
+	}, []string{"purpose", "outcome"})
+
+	LLMFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wally",
+		Name:      "llm_fallbacks_total",
+		Help:      "Mensagens tratadas sem o LLM, por motivo (unavailable, daily_limit) e modo (rules: regras locais; degraded_reply: aviso ao usuário).",
+	}, []string{"reason", "mode"})
+
+	LLMTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wally",
+		Name:      "llm_tokens_total",
+		Help:      "Tokens consumidos nas chamadas ao provedor de IA, por finalidade, modelo e tipo (prompt, output).",
+	}, []string{"purpose", "model", "kind"})
+
+	LLMCost = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wally",
+		Name:      "llm_cost_usd_total",
+		Help:      "Custo estimado em USD das chamadas ao provedor de IA, por finalidade e modelo (só modelos de preço conhecido).",
+	}, []string{"purpose", "model"})
+
+	SuspiciousInputs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wally",
+		Name:      "suspicious_inputs_total",
+		Help:      "Textos com indícios de prompt injection, por origem (mensagem ou conhecimento) e padrão.",
+	}, []string{"source", "pattern"})
+
+	OutboundMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wally",
+		Name:      "outbound_messages_total",
+		Help:      "Mensagens enviadas pela WaSenderAPI, por tipo e resultado.",
+	}, []string{"type", "result"})
+
+	RAGRetrievalDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "wally",
+		Name:      "rag_retrieval_duration_seconds",
+		Help:      "Tempo para recuperar o contexto aprendido (RAG) de um usuário.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "wally",
+		Name:      "db_query_duration_seconds",
+		Help:      "Latência das consultas ao PostgreSQL, por operação.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+)
+
+// Handler retorna o handler HTTP que expõe as métricas.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Since retorna os segundos decorridos desde start, para observar em histogramas.
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -1,120 +1,515 @@
 package rag
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
+	"sync"
 	"time"
 	"wally/internal/domain"
+	"wally/internal/llm"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+	"wally/internal/telemetry"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var dbSystem = attribute.String("db.system", "postgresql")
+
+// sameMappingSQL é o equivalente de SameMapping no upsert de SaveKnowledge, comparando a
+// entrada existente com a nova.
+const sameMappingSQL = `knowledge_entries.resulting_action IS NOT DISTINCT FROM EXCLUDED.resulting_action
+            AND lower(knowledge_entries.resulting_parameters->>'category') IS NOT DISTINCT FROM lower(EXCLUDED.resulting_parameters->>'category')`
+
 // KnowledgeRepository define a interface para persistir e recuperar conhecimento.
 type KnowledgeRepository interface {
-	SaveKnowledge(entry domain.KnowledgeEntry) error
-	RetrieveRelevantKnowledge(userID string, currentQuery string) (string, error)
+	SaveKnowledge(ctx context.Context, entry domain.KnowledgeEntry) error
+	RetrieveRelevantKnowledge(ctx context.Context, userID string, currentQuery string) (Knowledge, error)
+	// ReinforceKnowledge registra que as entradas levaram a uma ação confirmada.
+	ReinforceKnowledge(ctx context.Context, userID string, ids []int) error
+	// PruneKnowledge apaga as entradas de todos os usuários sem uso desde unusedSince e
+	// retorna quantas eram.
+	PruneKnowledge(ctx context.Context, unusedSince time.Time) (int, error)
+
+	// ListKnowledge retorna todas as entradas do usuário, da usada mais recentemente para a
+	// mais antiga.
+	ListKnowledge(ctx context.Context, userID string) ([]domain.KnowledgeEntry, error)
+	// DeleteKnowledge apaga a entrada do usuário e informa se ela existia.
+	DeleteKnowledge(ctx context.Context, userID string, id int) (bool, error)
+	// DeleteAllKnowledge apaga todas as entradas do usuário e retorna quantas eram.
+	DeleteAllKnowledge(ctx context.Context, userID string) (int, error)
+}
+
+// Knowledge é o resultado da recuperação: as entradas relevantes, da mais para a menos
+// relevante, e o contexto montado com elas para o LLM.
+type Knowledge struct {
+	Entries []domain.KnowledgeEntry
+	Context string
+}
+
+// RetrievalOptions controla quais entradas a recuperação devolve.
+type RetrievalOptions struct {
+	TopK          int           // Número máximo de entradas
+	MinScore      float64       // Relevância mínima por palavras (full-text ou trigramas), de 0 a 1
+	MinSimilarity float64       // Similaridade de cosseno mínima entre embeddings, de 0 a 1
+	HalfLife      time.Duration // Tempo sem uso em que o peso de uma entrada cai pela metade (0 desativa)
 }
 
+// DefaultRetrievalOptions são os valores usados quando nada é configurado.
+var DefaultRetrievalOptions = RetrievalOptions{TopK: 3, MinScore: 0.3, MinSimilarity: 0.75, HalfLife: 90 * 24 * time.Hour}
+
 // PostgresKnowledgeRepository é uma implementação do KnowledgeRepository usando PostgreSQL.
 type PostgresKnowledgeRepository struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
+	retrieval    RetrievalOptions
+	embedder     llm.Embedder
+
+	vectorMu      sync.Mutex
+	vectorChecked bool
+	vectorColumn  bool
 }
 
 // NewPostgresKnowledgeRepository cria uma nova instância do repositório PostgreSQL.
-func NewPostgresKnowledgeRepository(db *sql.DB) KnowledgeRepository {
-	return &PostgresKnowledgeRepository{db: db}
+// Cada consulta é limitada a queryTimeout, além do prazo do contexto recebido. Com um
+// embedder, as entradas também são comparadas por significado; embedder pode ser nil.
+func NewPostgresKnowledgeRepository(db *sql.DB, queryTimeout time.Duration, retrieval RetrievalOptions, embedder llm.Embedder) KnowledgeRepository {
+	return &PostgresKnowledgeRepository{db: db, queryTimeout: queryTimeout, retrieval: retrieval, embedder: embedder}
 }
 
-// SaveKnowledge salva uma nova entrada de conhecimento no PostgreSQL.
-func (r *PostgresKnowledgeRepository) SaveKnowledge(entry domain.KnowledgeEntry) error {
+// hasVectorColumn informa se a migração criou a coluna do pgvector. O resultado é guardado
+// após a primeira consulta bem-sucedida.
+func (r *PostgresKnowledgeRepository) hasVectorColumn(ctx context.Context) bool {
+	r.vectorMu.Lock()
+	defer r.vectorMu.Unlock()
+	if r.vectorChecked {
+		return r.vectorColumn
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+	err := r.db.QueryRowContext(queryCtx, `
+    SELECT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'knowledge_entries' AND column_name = 'embedding_vector'
+    )`).Scan(&r.vectorColumn)
+	if err != nil {
+		logging.FromContext(ctx).Warn("erro ao verificar suporte a pgvector", slog.Any("error", err))
+		return false
+	}
+	r.vectorChecked = true
+	logging.FromContext(ctx).Info("RAG_DB: suporte a embeddings verificado", slog.Bool("pgvector", r.vectorColumn))
+	return r.vectorColumn
+}
+
+// embed gera o embedding do texto, se houver embedder. Falhas são registradas e a entrada
+// segue sem vetor: a busca por palavras continua funcionando.
+func (r *PostgresKnowledgeRepository) embed(ctx context.Context, text string) []float32 {
+	if r.embedder == nil || text == "" {
+		return nil
+	}
+	vector, err := r.embedder.Embed(ctx, text)
+	if err != nil {
+		logging.FromContext(ctx).Warn("erro ao gerar embedding, seguindo só com a busca por palavras", slog.Any("error", err))
+		return nil
+	}
+	return vector
+}
+
+// SaveKnowledge salva a entrada de conhecimento no PostgreSQL. Se o usuário já tem uma
+// entrada para a mesma mensagem normalizada, ela é atualizada: com o mesmo mapeamento
+// (ação e categoria) é reforçada; com outro, o novo substitui o antigo e a contagem recomeça.
+func (r *PostgresKnowledgeRepository) SaveKnowledge(ctx context.Context, entry domain.KnowledgeEntry) (err error) {
+	ctx, span := telemetry.Start(ctx, "db.save_knowledge", dbSystem, attribute.String("db.operation.name", "INSERT"))
+	defer func() { telemetry.End(span, err) }()
+
 	paramsJSON, err := json.Marshal(entry.ResultingParameters)
 	if err != nil {
 		return fmt.Errorf("erro ao converter parâmetros para JSON: %w", err)
 	}
+	var rejectedJSON any // NULL sem exemplo negativo
+	if len(entry.RejectedParameters) > 0 {
+		if rejectedJSON, err = json.Marshal(entry.RejectedParameters); err != nil {
+			return fmt.Errorf("erro ao converter parâmetros rejeitados para JSON: %w", err)
+		}
+	}
 
-	query := `
-    INSERT INTO knowledge_entries (user_id, original_query, clarification_query, resulting_action, resulting_parameters, timestamp)
-    VALUES ($1, $2, $3, $4, $5, $6)`
-
-	_, err = r.db.Exec(query,
+	embedding := r.embed(ctx, embeddingText(entry))
+	args := []any{
 		entry.UserID,
+		NormalizeQuery(entry.OriginalQuery),
 		entry.OriginalQuery,
 		sql.NullString{String: entry.ClarificationQuery, Valid: entry.ClarificationQuery != ""},
 		entry.ResultingAction,
 		paramsJSON,
 		time.Now(), // Usar o tempo atual no momento da inserção
-	)
+		pq.Float32Array(embedding),
+		rejectedJSON,
+	}
+	columns := "user_id, normalized_query, original_query, clarification_query, resulting_action, resulting_parameters, timestamp, last_used_at, embedding, rejected_parameters"
+	values := "$1, $2, $3, $4, $5, $6, $7, $7, $8, $9"
+	// Sem um embedding novo (falha do embedder), o anterior é mantido.
+	updates := "embedding = coalesce(EXCLUDED.embedding, knowledge_entries.embedding)"
+	if embedding != nil && r.hasVectorColumn(ctx) {
+		columns += ", embedding_vector"
+		values += ", $10::vector"
+		updates += ", embedding_vector = EXCLUDED.embedding_vector"
+		args = append(args, vectorLiteral(embedding))
+	}
+	query := `
+    INSERT INTO knowledge_entries (` + columns + `)
+    VALUES (` + values + `)
+    ON CONFLICT (user_id, normalized_query) DO UPDATE SET
+        hit_count = CASE WHEN ` + sameMappingSQL + ` THEN knowledge_entries.hit_count + 1 ELSE 1 END,
+        -- Reforçar o mesmo mapeamento não apaga o exemplo negativo já aprendido.
+        rejected_parameters = CASE WHEN ` + sameMappingSQL + `
+            THEN coalesce(EXCLUDED.rejected_parameters, knowledge_entries.rejected_parameters)
+            ELSE EXCLUDED.rejected_parameters
+        END,
+        original_query = EXCLUDED.original_query,
+        clarification_query = EXCLUDED.clarification_query,
+        resulting_action = EXCLUDED.resulting_action,
+        resulting_parameters = EXCLUDED.resulting_parameters,
+        last_used_at = EXCLUDED.last_used_at,
+        ` + updates + `
+    RETURNING hit_count`
+
+	queryCtx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	var hitCount int
+	err = r.db.QueryRowContext(queryCtx, query, args...).Scan(&hitCount)
+	metrics.DBQueryDuration.WithLabelValues("save_knowledge").Observe(metrics.Since(start))
 
 	if err != nil {
 		return fmt.Errorf("erro ao salvar conhecimento no banco de dados: %w", err)
 	}
 
-	log.Printf("RAG_DB: Conhecimento salvo para UserID %s: Original='%s', Clarif='%s', Action='%s'",
-		entry.UserID, entry.OriginalQuery, entry.ClarificationQuery, entry.ResultingAction)
+	logging.FromContext(ctx).Info("RAG_DB: conhecimento salvo",
+		logging.Phone(entry.UserID),
+		logging.Sensitive("original_query", entry.OriginalQuery),
+		logging.Sensitive("clarification_query", entry.ClarificationQuery),
+		slog.String("action", entry.ResultingAction),
+		slog.Int("hit_count", hitCount),
+		slog.Bool("embedding", embedding != nil))
 	return nil
 }
 
-// RetrieveRelevantKnowledge recupera conhecimento relevante do PostgreSQL.
-// Esta é uma implementação simples; RAGs mais avançados usariam embeddings e busca vetorial.
-func (r *PostgresKnowledgeRepository) RetrieveRelevantKnowledge(userID string, currentQuery string) (string, error) {
-	// Lógica de recuperação simples: pegar as últimas N interações para o usuário.
-	// Em um sistema real, você selecionaria as mais relevantes para `currentQuery`
-	// usando técnicas como busca full-text, similaridade de strings, ou embeddings.
+// RetrieveRelevantKnowledge recupera as entradas do usuário mais parecidas com a mensagem
+// atual. Cada entrada recebe notas de 0 a 1: a fração dos termos da mensagem (após o
+// stemming em português) presentes na entrada, a similaridade por trigramas com a mensagem
+// original e, com embeddings, a similaridade de cosseno. Entram as top-k que atingem o
+// mínimo por palavras ou por significado, ponderadas pelo uso de cada uma (veja rank).
+func (r *PostgresKnowledgeRepository) RetrieveRelevantKnowledge(ctx context.Context, userID string, currentQuery string) (_ Knowledge, err error) {
+	ctx, span := telemetry.Start(ctx, "rag.retrieve_knowledge")
+	defer func() { telemetry.End(span, err) }()
+	logger := logging.FromContext(ctx)
+	retrievalStart := time.Now()
+	defer func() { metrics.RAGRetrievalDuration.Observe(metrics.Since(retrievalStart)) }()
+
+	queryEmbedding := r.embed(ctx, currentQuery)
+
+	// Com pgvector o cosseno é calculado no banco; sem ele, os vetores são devolvidos e
+	// comparados em Go. A dimensão é conferida para tolerar a troca do modelo de embedding.
+	semanticExpr, embeddingExpr := "NULL::float8", "NULL::float8[]"
+	args := []any{userID, currentQuery}
+	switch {
+	case queryEmbedding == nil:
+	case r.hasVectorColumn(ctx):
+		semanticExpr = `CASE WHEN e.embedding_vector IS NOT NULL AND vector_dims(e.embedding_vector) = vector_dims($3::vector)
+                THEN 1 - (e.embedding_vector <=> $3::vector) END`
+		args = append(args, vectorLiteral(queryEmbedding))
+	default:
+		embeddingExpr = "e.embedding"
+	}
+
+	// As notas são calculadas para todas as entradas do usuário, que são poucas; o filtro
+	// por user_id usa o índice idx_knowledge_entries_user_timestamp.
 	query := `
-    SELECT original_query, clarification_query, resulting_action, resulting_parameters
-    FROM knowledge_entries
-    WHERE user_id = $1
-    ORDER BY timestamp DESC
-    LIMIT 3` // Pega as últimas 3 entradas, por exemplo
+    WITH query AS (
+        SELECT tsvector_to_array(to_tsvector('portuguese', $2)) AS lexemes
+    )
+    SELECT e.id, e.original_query, e.clarification_query, e.resulting_action, e.resulting_parameters,
+        e.rejected_parameters, e.hit_count, e.last_used_at,
+        CASE WHEN cardinality(q.lexemes) = 0 THEN 0 ELSE (
+            SELECT count(*)::float8 / cardinality(q.lexemes)
+            FROM unnest(q.lexemes) AS lexeme
+            WHERE lexeme = ANY (tsvector_to_array(e.search_vector))
+        ) END AS fts_score,
+        similarity(coalesce(e.original_query, ''), $2)::float8 AS trgm_score,
+        ` + semanticExpr + ` AS semantic_score,
+        ` + embeddingExpr + ` AS embedding
+    FROM knowledge_entries e, query q
+    WHERE e.user_id = $1
+    ORDER BY e.last_used_at DESC`
 
-	rows, err := r.db.Query(query, userID)
+	_, querySpan := telemetry.Start(ctx, "db.retrieve_knowledge", dbSystem, attribute.String("db.operation.name", "SELECT"))
+	queryCtx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	rows, err := r.db.QueryContext(queryCtx, query, args...)
+	metrics.DBQueryDuration.WithLabelValues("retrieve_knowledge").Observe(metrics.Since(start))
+	telemetry.End(querySpan, err)
 	if err != nil {
-		return "", fmt.Errorf("erro ao buscar conhecimento no banco de dados: %w", err)
+		return Knowledge{}, fmt.Errorf("erro ao buscar conhecimento no banco de dados: %w", err)
 	}
 	defer rows.Close()
 
-	var relevantContext strings.Builder
-	var entries []domain.KnowledgeEntry // Para reconstruir o contexto
+	var candidates []candidate
 
 	for rows.Next() {
 		var entry domain.KnowledgeEntry
-		var paramsJSON []byte
-		var clarificationQuery sql.NullString
+		var paramsJSON, rejectedJSON []byte
+		var originalQuery, clarificationQuery sql.NullString
+		var semantic sql.NullFloat64
+		var embedding pq.Float32Array
+		var score Score
 
-		if err := rows.Scan(&entry.OriginalQuery, &clarificationQuery, &entry.ResultingAction, &paramsJSON); err != nil {
-			log.Printf("Erro ao escanear linha de conhecimento: %v", err)
+		if err := rows.Scan(&entry.ID, &originalQuery, &clarificationQuery, &entry.ResultingAction, &paramsJSON,
+			&rejectedJSON, &entry.HitCount, &entry.LastUsedAt, &score.FullText, &score.Trigram, &semantic, &embedding); err != nil {
+			logger.Warn("erro ao escanear linha de conhecimento", slog.Any("error", err))
 			continue // Pula entradas malformadas
 		}
-		if clarificationQuery.Valid {
-			entry.ClarificationQuery = clarificationQuery.String
+		entry.OriginalQuery = originalQuery.String
+		entry.ClarificationQuery = clarificationQuery.String
+		if semantic.Valid {
+			score.Semantic = semantic.Float64
+		} else {
+			score.Semantic = cosineSimilarity(queryEmbedding, embedding)
 		}
 
 		if err := json.Unmarshal(paramsJSON, &entry.ResultingParameters); err != nil {
-			log.Printf("Erro ao fazer unmarshal dos parâmetros do JSON do BD: %v", err)
+			logger.Warn("erro ao fazer unmarshal dos parâmetros do JSON do BD", slog.Any("error", err))
 			// Continuar mesmo se os parâmetros não puderem ser decodificados,
 			// o resto da entrada ainda pode ser útil.
 			entry.ResultingParameters = make(map[string]string) // Define como vazio para evitar nil pointer
 		}
+		entry.RejectedParameters = decodeRejected(ctx, rejectedJSON)
+		score.EntryID = entry.ID
+		candidates = append(candidates, candidate{entry: entry, score: score})
+	}
+	if err := rows.Err(); err != nil {
+		return Knowledge{}, fmt.Errorf("erro durante iteração das linhas de conhecimento: %w", err)
+	}
+
+	entries, scores := rank(candidates, r.retrieval, time.Now())
+	span.SetAttributes(
+		attribute.Int("wally.rag.candidates", len(candidates)),
+		attribute.Int("wally.rag.entries", len(entries)),
+		attribute.Bool("wally.rag.semantic", queryEmbedding != nil))
+	logger.Debug("RAG_DB: entradas ranqueadas",
+		logging.Phone(userID),
+		slog.Int("candidates", len(candidates)),
+		slog.Int("entries", len(entries)),
+		slog.Bool("semantic", queryEmbedding != nil),
+		slog.Any("scores", scores))
+
+	relevantContext := BuildContext(entries)
+	if relevantContext != "" {
+		logger.Debug("RAG_DB: contexto recuperado",
+			logging.Phone(userID),
+			logging.Sensitive("context", relevantContext))
+	}
+	return Knowledge{Entries: entries, Context: relevantContext}, nil
+}
+
+// decodeRejected lê os parâmetros rejeitados (exemplo negativo), que costumam ser NULL.
+func decodeRejected(ctx context.Context, data []byte) map[string]string {
+	if len(data) == 0 {
+		return nil
+	}
+	var rejected map[string]string
+	if err := json.Unmarshal(data, &rejected); err != nil {
+		logging.FromContext(ctx).Warn("erro ao fazer unmarshal dos parâmetros rejeitados do JSON do BD", slog.Any("error", err))
+		return nil
+	}
+	return rejected
+}
+
+// ReinforceKnowledge incrementa o contador de uso das entradas e renova seu último uso.
+func (r *PostgresKnowledgeRepository) ReinforceKnowledge(ctx context.Context, userID string, ids []int) (err error) {
+	if len(ids) == 0 {
+		return nil
+	}
+	ctx, span := telemetry.Start(ctx, "db.reinforce_knowledge", dbSystem, attribute.String("db.operation.name", "UPDATE"))
+	defer func() { telemetry.End(span, err) }()
+
+	entryIDs := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		entryIDs[i] = int64(id)
+	}
+	query := `
+    UPDATE knowledge_entries
+    SET hit_count = hit_count + 1, last_used_at = $3
+    WHERE user_id = $1 AND id = ANY ($2)`
+
+	queryCtx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err = r.db.ExecContext(queryCtx, query, userID, entryIDs, time.Now())
+	metrics.DBQueryDuration.WithLabelValues("reinforce_knowledge").Observe(metrics.Since(start))
+	if err != nil {
+		return fmt.Errorf("erro ao reforçar conhecimento no banco de dados: %w", err)
+	}
+	logging.FromContext(ctx).Debug("RAG_DB: conhecimento reforçado", logging.Phone(userID), slog.Any("ids", ids))
+	return nil
+}
+
+// PruneKnowledge apaga as entradas de todos os usuários sem uso desde unusedSince.
+func (r *PostgresKnowledgeRepository) PruneKnowledge(ctx context.Context, unusedSince time.Time) (_ int, err error) {
+	ctx, span := telemetry.Start(ctx, "db.prune_knowledge", dbSystem, attribute.String("db.operation.name", "DELETE"))
+	defer func() { telemetry.End(span, err) }()
+
+	n, err := r.delete(ctx, "prune_knowledge", `DELETE FROM knowledge_entries WHERE last_used_at < $1`, unusedSince)
+	if err != nil {
+		return 0, err
+	}
+	logging.FromContext(ctx).Info("RAG_DB: conhecimento sem uso apagado", slog.Time("unused_since", unusedSince), slog.Int64("entries", n))
+	return int(n), nil
+}
+
+// ListKnowledge retorna todas as entradas do usuário, da usada mais recentemente para a mais
+// antiga.
+func (r *PostgresKnowledgeRepository) ListKnowledge(ctx context.Context, userID string) (_ []domain.KnowledgeEntry, err error) {
+	ctx, span := telemetry.Start(ctx, "db.list_knowledge", dbSystem, attribute.String("db.operation.name", "SELECT"))
+	defer func() { telemetry.End(span, err) }()
+
+	query := `
+    SELECT id, original_query, clarification_query, resulting_action, resulting_parameters, timestamp,
+        rejected_parameters, hit_count, last_used_at
+    FROM knowledge_entries
+    WHERE user_id = $1
+    ORDER BY last_used_at DESC, id DESC`
+
+	queryCtx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	rows, err := r.db.QueryContext(queryCtx, query, userID)
+	metrics.DBQueryDuration.WithLabelValues("list_knowledge").Observe(metrics.Since(start))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao listar conhecimento no banco de dados: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []domain.KnowledgeEntry
+	for rows.Next() {
+		entry := domain.KnowledgeEntry{UserID: userID}
+		var paramsJSON, rejectedJSON []byte
+		var originalQuery, clarificationQuery sql.NullString
+		if err := rows.Scan(&entry.ID, &originalQuery, &clarificationQuery, &entry.ResultingAction, &paramsJSON, &entry.Timestamp,
+			&rejectedJSON, &entry.HitCount, &entry.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("erro ao escanear linha de conhecimento: %w", err)
+		}
+		entry.OriginalQuery = originalQuery.String
+		entry.ClarificationQuery = clarificationQuery.String
+		if err := json.Unmarshal(paramsJSON, &entry.ResultingParameters); err != nil {
+			logging.FromContext(ctx).Warn("erro ao fazer unmarshal dos parâmetros do JSON do BD", slog.Any("error", err))
+			entry.ResultingParameters = make(map[string]string)
+		}
+		entry.RejectedParameters = decodeRejected(ctx, rejectedJSON)
 		entries = append(entries, entry)
 	}
 	if err := rows.Err(); err != nil {
-		return "", fmt.Errorf("erro durante iteração das linhas de conhecimento: %w", err)
+		return nil, fmt.Errorf("erro durante iteração das linhas de conhecimento: %w", err)
+	}
+	return entries, nil
+}
+
+// DeleteKnowledge apaga a entrada do usuário e informa se ela existia. O filtro por
+// user_id impede que um usuário apague o conhecimento de outro.
+func (r *PostgresKnowledgeRepository) DeleteKnowledge(ctx context.Context, userID string, id int) (_ bool, err error) {
+	ctx, span := telemetry.Start(ctx, "db.delete_knowledge", dbSystem, attribute.String("db.operation.name", "DELETE"))
+	defer func() { telemetry.End(span, err) }()
+
+	n, err := r.delete(ctx, "delete_knowledge", `DELETE FROM knowledge_entries WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return false, err
+	}
+	logging.FromContext(ctx).Info("RAG_DB: conhecimento apagado", logging.Phone(userID), slog.Int("id", id), slog.Bool("found", n > 0))
+	return n > 0, nil
+}
+
+// DeleteAllKnowledge apaga todas as entradas do usuário e retorna quantas eram.
+func (r *PostgresKnowledgeRepository) DeleteAllKnowledge(ctx context.Context, userID string) (_ int, err error) {
+	ctx, span := telemetry.Start(ctx, "db.delete_all_knowledge", dbSystem, attribute.String("db.operation.name", "DELETE"))
+	defer func() { telemetry.End(span, err) }()
+
+	n, err := r.delete(ctx, "delete_all_knowledge", `DELETE FROM knowledge_entries WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	logging.FromContext(ctx).Info("RAG_DB: todo o conhecimento apagado", logging.Phone(userID), slog.Int64("entries", n))
+	return int(n), nil
+}
+
+// delete executa um DELETE com o timeout do repositório e retorna as linhas afetadas.
+func (r *PostgresKnowledgeRepository) delete(ctx context.Context, operation string, query string, args ...any) (int64, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := r.db.ExecContext(queryCtx, query, args...)
+	metrics.DBQueryDuration.WithLabelValues(operation).Observe(metrics.Since(start))
+	if err != nil {
+		return 0, fmt.Errorf("erro ao apagar conhecimento no banco de dados: %w", err)
 	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("erro ao contar o conhecimento apagado: %w", err)
+	}
+	return n, nil
+}
 
-	// Construir o string de contexto (invertendo a ordem para mais recente primeiro no prompt)
+// BuildContext monta o texto de contexto enviado ao LLM a partir das entradas, que chegam
+// da mais para a menos relevante; o texto as apresenta em ordem inversa, deixando a mais
+// relevante perto da mensagem do usuário. Os textos do usuário vão entre aspas e escapados,
+// para que não se confundam com o restante do contexto.
+func BuildContext(entries []domain.KnowledgeEntry) string {
+	var relevantContext strings.Builder
 	for i := len(entries) - 1; i >= 0; i-- {
 		entry := entries[i]
-		contextPiece := fmt.Sprintf("Anteriormente, quando o usuário disse algo como '%s'", entry.OriginalQuery)
+		contextPiece := fmt.Sprintf("Anteriormente, quando o usuário disse algo como %q", entry.OriginalQuery)
 		if entry.ClarificationQuery != "" {
-			contextPiece += fmt.Sprintf(" e depois esclareceu com '%s'", entry.ClarificationQuery)
+			contextPiece += fmt.Sprintf(" e depois esclareceu com %q", entry.ClarificationQuery)
 		}
-		contextPiece += fmt.Sprintf(", a intenção foi '%s' com parâmetros '%v'.\n", entry.ResultingAction, entry.ResultingParameters)
+		contextPiece += fmt.Sprintf(", a intenção foi '%s'", entry.ResultingAction)
+		if params := describeParameters(entry.ResultingParameters); params != "" {
+			contextPiece += " com " + params
+		}
+		if rejected := entry.RejectedParameters["category"]; rejected != "" {
+			contextPiece += fmt.Sprintf(" (a categoria não é '%s', mas '%s')", rejected, entry.ResultingParameters["category"])
+		}
+		contextPiece += ".\n"
 		relevantContext.WriteString(contextPiece)
 	}
+	return relevantContext.String()
+}
 
-	if relevantContext.Len() > 0 {
-		log.Printf("RAG_DB: Contexto recuperado para UserID %s: %s", userID, relevantContext.String())
+// parameterLabels são os parâmetros aprendidos que vão para o contexto, na ordem e com os
+// nomes usados nele. O valor não entra: muda a cada despesa (veja SameMapping) e o modelo
+// poderia copiá-lo da mensagem antiga.
+var parameterLabels = []struct{ key, label string }{
+	{"category", "categoria"},
+	{"description", "descrição"},
+}
+
+// describeParameters escreve os parâmetros como "categoria: Mercado, descrição: feira",
+// em vez do formato de mapa do Go, que o modelo repetiria nas respostas.
+func describeParameters(params map[string]string) string {
+	var parts []string
+	for _, p := range parameterLabels {
+		if value := strings.TrimSpace(params[p.key]); value != "" {
+			parts = append(parts, p.label+": "+value)
+		}
 	}
-	return relevantContext.String(), nil
+	return strings.Join(parts, ", ")
 }
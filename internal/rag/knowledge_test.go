@@ -0,0 +1,32 @@
+package rag
+
+import (
+	"testing"
+	"wally/internal/domain"
+)
+
+func TestBuildContext(t *testing.T) {
+	entries := []domain.KnowledgeEntry{
+		{
+			OriginalQuery:       "uber 18",
+			ResultingAction:     "add_expense",
+			ResultingParameters: map[string]string{"amount": "18", "category": "Trabalho"},
+			RejectedParameters:  map[string]string{"category": "Transporte"},
+		},
+		{
+			OriginalQuery:       "rango",
+			ClarificationQuery:  "é comida",
+			ResultingAction:     "add_expense",
+			ResultingParameters: map[string]string{"amount": "20", "category": "Alimentação", "description": "almoço"},
+		},
+		{OriginalQuery: "ajuda", ResultingAction: "show_menu", ResultingParameters: map[string]string{}},
+	}
+
+	want := `Anteriormente, quando o usuário disse algo como "ajuda", a intenção foi 'show_menu'.
+Anteriormente, quando o usuário disse algo como "rango" e depois esclareceu com "é comida", a intenção foi 'add_expense' com categoria: Alimentação, descrição: almoço.
+Anteriormente, quando o usuário disse algo como "uber 18", a intenção foi 'add_expense' com categoria: Trabalho (a categoria não é 'Transporte', mas 'Trabalho').
+`
+	if got := BuildContext(entries); got != want {
+		t.Errorf("BuildContext() =\n%s\nwant:\n%s", got, want)
+	}
+}
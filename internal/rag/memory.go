@@ -0,0 +1,205 @@
+package rag
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+	"wally/internal/domain"
+	"wally/internal/llm"
+	"wally/internal/logging"
+)
+
+// MemoryKnowledgeRepository guarda o conhecimento em memória. Serve para testes e para
+// rodar o bot sem banco de dados.
+type MemoryKnowledgeRepository struct {
+	mu         sync.Mutex
+	entries    []domain.KnowledgeEntry
+	embeddings map[int][]float32
+	nextID     int
+	retrieval  RetrievalOptions
+	embedder   llm.Embedder
+}
+
+// NewMemoryKnowledgeRepository cria um repositório em memória vazio, com as opções de
+// recuperação padrão. Com um embedder, as entradas também são comparadas por significado,
+// por força bruta; embedder pode ser nil.
+func NewMemoryKnowledgeRepository(embedder llm.Embedder) *MemoryKnowledgeRepository {
+	return &MemoryKnowledgeRepository{
+		embeddings: make(map[int][]float32),
+		retrieval:  DefaultRetrievalOptions,
+		embedder:   embedder,
+	}
+}
+
+// embed gera o embedding do texto, se houver embedder; falhas resultam em nil, como no
+// repositório PostgreSQL.
+func (r *MemoryKnowledgeRepository) embed(ctx context.Context, text string) []float32 {
+	if r.embedder == nil || text == "" {
+		return nil
+	}
+	vector, err := r.embedder.Embed(ctx, text)
+	if err != nil {
+		logging.FromContext(ctx).Warn("erro ao gerar embedding, seguindo só com a busca por palavras", slog.Any("error", err))
+		return nil
+	}
+	return vector
+}
+
+// SaveKnowledge adiciona a entrada ao repositório ou atualiza a do usuário com a mesma
+// mensagem normalizada, com as mesmas regras de reforço do repositório PostgreSQL.
+func (r *MemoryKnowledgeRepository) SaveKnowledge(ctx context.Context, entry domain.KnowledgeEntry) error {
+	embedding := r.embed(ctx, embeddingText(entry))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	entry.Timestamp, entry.LastUsedAt, entry.HitCount = now, now, 1
+	normalized := NormalizeQuery(entry.OriginalQuery)
+	if i := slices.IndexFunc(r.entries, func(e domain.KnowledgeEntry) bool {
+		return e.UserID == entry.UserID && NormalizeQuery(e.OriginalQuery) == normalized
+	}); i >= 0 {
+		existing := r.entries[i]
+		entry.ID, entry.Timestamp = existing.ID, existing.Timestamp
+		if SameMapping(existing, entry) {
+			entry.HitCount = existing.HitCount + 1
+			if entry.RejectedParameters == nil {
+				entry.RejectedParameters = existing.RejectedParameters
+			}
+		}
+		r.entries = slices.Delete(r.entries, i, i+1)
+	} else {
+		r.nextID++
+		entry.ID = r.nextID
+	}
+	r.entries = append(r.entries, entry)
+	if embedding != nil {
+		r.embeddings[entry.ID] = embedding
+	}
+	return nil
+}
+
+// RetrieveRelevantKnowledge ranqueia as entradas do usuário pela mensagem atual com as
+// mesmas regras do repositório PostgreSQL e retorna o contexto no mesmo formato.
+func (r *MemoryKnowledgeRepository) RetrieveRelevantKnowledge(ctx context.Context, userID string, currentQuery string) (Knowledge, error) {
+	queryEmbedding := r.embed(ctx, currentQuery)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var candidates []candidate
+	// As entradas ficam na ordem do último uso; percorre da usada mais recentemente para a
+	// mais antiga, para que o desempate favoreça as recentes.
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		entry := r.entries[i]
+		if entry.UserID != userID {
+			continue
+		}
+		candidates = append(candidates, candidate{entry: entry, score: Score{
+			EntryID:  entry.ID,
+			FullText: termCoverage(currentQuery, entry.OriginalQuery+" "+entry.ClarificationQuery),
+			Trigram:  trigramSimilarity(entry.OriginalQuery, currentQuery),
+			Semantic: cosineSimilarity(queryEmbedding, r.embeddings[entry.ID]),
+		}})
+	}
+
+	entries, scores := rank(candidates, r.retrieval, time.Now())
+	logging.FromContext(ctx).Debug("RAG_MEM: entradas ranqueadas",
+		logging.Phone(userID),
+		slog.Int("candidates", len(candidates)),
+		slog.Int("entries", len(entries)),
+		slog.Bool("semantic", queryEmbedding != nil),
+		slog.Any("scores", scores))
+	return Knowledge{Entries: entries, Context: BuildContext(entries)}, nil
+}
+
+// ReinforceKnowledge incrementa o contador de uso das entradas e renova seu último uso.
+func (r *MemoryKnowledgeRepository) ReinforceKnowledge(ctx context.Context, userID string, ids []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var reinforced []domain.KnowledgeEntry
+	r.entries = slices.DeleteFunc(r.entries, func(entry domain.KnowledgeEntry) bool {
+		if entry.UserID != userID || !slices.Contains(ids, entry.ID) {
+			return false
+		}
+		entry.HitCount++
+		entry.LastUsedAt = time.Now()
+		reinforced = append(reinforced, entry)
+		return true
+	})
+	r.entries = append(r.entries, reinforced...)
+	return nil
+}
+
+// PruneKnowledge apaga as entradas de todos os usuários sem uso desde unusedSince.
+func (r *MemoryKnowledgeRepository) PruneKnowledge(ctx context.Context, unusedSince time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := len(r.entries)
+	r.entries = slices.DeleteFunc(r.entries, func(entry domain.KnowledgeEntry) bool {
+		if !entry.LastUsedAt.Before(unusedSince) {
+			return false
+		}
+		delete(r.embeddings, entry.ID)
+		return true
+	})
+	return before - len(r.entries), nil
+}
+
+// ListKnowledge retorna todas as entradas do usuário, da usada mais recentemente para a mais
+// antiga.
+func (r *MemoryKnowledgeRepository) ListKnowledge(ctx context.Context, userID string) ([]domain.KnowledgeEntry, error) {
+	entries := r.Entries(userID)
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// DeleteKnowledge apaga a entrada do usuário e informa se ela existia.
+func (r *MemoryKnowledgeRepository) DeleteKnowledge(ctx context.Context, userID string, id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := len(r.entries)
+	r.entries = slices.DeleteFunc(r.entries, func(entry domain.KnowledgeEntry) bool {
+		return entry.UserID == userID && entry.ID == id
+	})
+	if len(r.entries) == before {
+		return false, nil
+	}
+	delete(r.embeddings, id)
+	return true, nil
+}
+
+// DeleteAllKnowledge apaga todas as entradas do usuário e retorna quantas eram.
+func (r *MemoryKnowledgeRepository) DeleteAllKnowledge(ctx context.Context, userID string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := len(r.entries)
+	r.entries = slices.DeleteFunc(r.entries, func(entry domain.KnowledgeEntry) bool {
+		if entry.UserID != userID {
+			return false
+		}
+		delete(r.embeddings, entry.ID)
+		return true
+	})
+	return before - len(r.entries), nil
+}
+
+// Entries retorna uma cópia de todas as entradas do usuário, na ordem do último uso.
+func (r *MemoryKnowledgeRepository) Entries(userID string) []domain.KnowledgeEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var entries []domain.KnowledgeEntry
+	for _, entry := range r.entries {
+		if entry.UserID == userID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,122 @@
+package rag
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"wally/internal/domain"
+)
+
+// Score é a relevância de uma entrada para a mensagem atual, registrada nos logs de debug.
+type Score struct {
+	EntryID  int     `json:"entry_id"`
+	FullText float64 `json:"fts"`
+	Trigram  float64 `json:"trgm"`
+	Semantic float64 `json:"semantic"`
+	Weight   float64 `json:"weight"`
+	Score    float64 `json:"score"`
+}
+
+// minDecay é o menor fator de recência: uma entrada antiga, mas muito parecida com a
+// mensagem, ainda pode ser escolhida até ser apagada por PruneKnowledge.
+const minDecay = 0.25
+
+// candidate é uma entrada do usuário com suas notas de relevância.
+type candidate struct {
+	entry domain.KnowledgeEntry
+	score Score
+}
+
+// rank descarta as entradas que não atingem MinScore por palavras nem MinSimilarity por
+// embeddings e devolve as top-k, da mais para a menos relevante. As notas por palavras e
+// por significado têm escalas diferentes, por isso cada uma tem seu mínimo; a ordenação usa
+// a maior delas multiplicada pelo peso da entrada (veja weight). candidates deve vir da
+// entrada usada mais recentemente para a mais antiga, para que o desempate favoreça as
+// recentes.
+func rank(candidates []candidate, opts RetrievalOptions, now time.Time) ([]domain.KnowledgeEntry, []Score) {
+	var kept []candidate
+	for _, c := range candidates {
+		keyword := max(c.score.FullText, c.score.Trigram)
+		semanticOK := c.score.Semantic > 0 && c.score.Semantic >= opts.MinSimilarity
+		if keyword < opts.MinScore && !semanticOK {
+			continue
+		}
+		relevance := keyword
+		if semanticOK {
+			relevance = max(keyword, c.score.Semantic)
+		}
+		c.score.Weight = weight(c.entry, opts.HalfLife, now)
+		c.score.Score = relevance * c.score.Weight
+		kept = append(kept, c)
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].score.Score > kept[j].score.Score })
+	if len(kept) > opts.TopK {
+		kept = kept[:opts.TopK]
+	}
+
+	entries := make([]domain.KnowledgeEntry, len(kept))
+	scores := make([]Score, len(kept))
+	for i, c := range kept {
+		entries[i], scores[i] = c.entry, c.score
+	}
+	return entries, scores
+}
+
+// weight pondera a relevância pelo histórico da entrada: cresce devagar com os usos
+// confirmados (1 para um único uso) e cai pela metade a cada halfLife sem uso, até minDecay.
+func weight(entry domain.KnowledgeEntry, halfLife time.Duration, now time.Time) float64 {
+	w := 1 + math.Log(float64(max(entry.HitCount, 1)))/4
+	if halfLife > 0 && !entry.LastUsedAt.IsZero() {
+		if idle := now.Sub(entry.LastUsedAt); idle > 0 {
+			w *= max(math.Exp2(-idle.Hours()/halfLife.Hours()), minDecay)
+		}
+	}
+	return w
+}
+
+// amountToken reconhece as palavras que são só um valor, como "30", "23,90" e "r$18.50".
+var amountToken = regexp.MustCompile(`^(?:r\$[0-9.,]*|[0-9][0-9.,]*)$`)
+
+// NormalizeQuery é a forma da mensagem original usada para identificar entradas repetidas:
+// minúsculas, sem os valores e com os espaços colapsados, para que "uber 30" e "uber 18"
+// sejam a mesma entrada. Uma mensagem só com valores fica com eles. A migração 0007 aplica
+// a mesma regra em SQL.
+func NormalizeQuery(query string) string {
+	words := strings.Fields(strings.ToLower(query))
+	kept := slices.DeleteFunc(slices.Clone(words), amountToken.MatchString)
+	if len(kept) == 0 {
+		kept = words
+	}
+	return strings.Join(kept, " ")
+}
+
+// SameMapping informa se duas entradas levam ao mesmo resultado: a mesma ação e, em
+// despesas, a mesma categoria. O valor muda a cada despesa e não conta.
+func SameMapping(a, b domain.KnowledgeEntry) bool {
+	return a.ResultingAction == b.ResultingAction &&
+		strings.EqualFold(a.ResultingParameters["category"], b.ResultingParameters["category"])
+}
+
+// embeddingText é o texto da entrada usado para gerar seu embedding: a mensagem original e
+// o esclarecimento, que costuma dizer com outras palavras o que o usuário quis.
+func embeddingText(entry domain.KnowledgeEntry) string {
+	return strings.TrimSpace(entry.OriginalQuery + "\n" + entry.ClarificationQuery)
+}
+
+// vectorLiteral formata o vetor no formato de entrada do pgvector ("[1,2,3]").
+func vectorLiteral(v []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
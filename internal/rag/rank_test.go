@@ -0,0 +1,54 @@
+package rag
+
+import (
+	"context"
+	"testing"
+	"wally/internal/domain"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"Uber 30", "uber"},
+		{"  uber   18 ", "uber"},
+		{"uber 23,90", "uber"},
+		{"mercado R$ 1.500,00", "mercado"},
+		{"r$30 padaria", "padaria"},
+		{"99 taxi 12", "taxi"},
+		{"ifood", "ifood"},
+		{"ifood2go 20", "ifood2go"},
+		{"30", "30"},
+		{"R$ 30", "r$ 30"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeQuery(tt.query); got != tt.want {
+			t.Errorf("NormalizeQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSaveKnowledgeIgnoresAmounts(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryKnowledgeRepository(nil)
+	for _, query := range []string{"uber 30", "Uber 18"} {
+		err := repo.SaveKnowledge(ctx, domain.KnowledgeEntry{
+			UserID:              "5511999999999",
+			OriginalQuery:       query,
+			ResultingAction:     "add_expense",
+			ResultingParameters: map[string]string{"category": "Transporte"},
+		})
+		if err != nil {
+			t.Fatalf("SaveKnowledge(%q): %v", query, err)
+		}
+	}
+
+	entries := repo.Entries("5511999999999")
+	if len(entries) != 1 {
+		t.Fatalf("entries = %d, want 1", len(entries))
+	}
+	if entries[0].HitCount != 2 || entries[0].OriginalQuery != "Uber 18" {
+		t.Errorf("entry = %+v, want the latest query with hit count 2", entries[0])
+	}
+}
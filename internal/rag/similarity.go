@@ -0,0 +1,82 @@
+package rag
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Aproximações em Go das notas calculadas no PostgreSQL, usadas pelo repositório em memória.
+// Não há stemming nem remoção de stopwords, então os valores são parecidos, não idênticos.
+
+// words separa o texto em palavras minúsculas, como o pg_trgm.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// trigrams gera os trigramas de cada palavra com o mesmo preenchimento do pg_trgm
+// (dois espaços antes e um depois).
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range words(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// trigramSimilarity equivale a similarity() do pg_trgm: trigramas em comum sobre o total.
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range ta {
+		if tb[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+// termCoverage é a fração das palavras da consulta presentes no documento.
+func termCoverage(query, document string) float64 {
+	queryWords := words(query)
+	if len(queryWords) == 0 {
+		return 0
+	}
+	present := make(map[string]bool)
+	for _, word := range words(document) {
+		present[word] = true
+	}
+	found := 0
+	for _, word := range queryWords {
+		if present[word] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(queryWords))
+}
+
+// cosineSimilarity é a similaridade de cosseno entre dois embeddings. Vetores vazios ou de
+// dimensões diferentes (gerados por outro modelo) resultam em 0.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
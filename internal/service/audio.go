@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"wally/internal/domain"
+	"wally/internal/logging"
+	"wally/internal/telemetry"
+	"wally/pkg/wasender"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Transcriber converte áudios em texto (speech-to-text).
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error)
+}
+
+// ProcessAudioMessage transcreve uma nota de voz e a processa como uma mensagem de texto,
+// repetindo a transcrição para que o usuário perceba erros de reconhecimento.
+func (b *Bot) ProcessAudioMessage(ctx context.Context, number string, name string, media domain.MediaMessage) {
+	ctx, span := telemetry.Start(ctx, "process_audio", attribute.String("wally.mime_type", media.MimeType))
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+	logger.Info("processando áudio", logging.Phone(number), logging.Sensitive("name", name), slog.String("mime_type", media.MimeType))
+
+	audio, err := b.media.DownloadMedia(ctx, media)
+	if errors.Is(err, wasender.ErrMediaTooLarge) {
+		logger.Warn("áudio acima do limite de tamanho", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Esse áudio é longo demais para eu ouvir. Poderia mandar um mais curto ou digitar a mensagem?")
+		return
+	}
+	if err != nil {
+		logger.Error("erro ao baixar áudio", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consegui baixar o áudio. Poderia enviar novamente?")
+		return
+	}
+
+	transcribeCtx, transcribeSpan := telemetry.Start(ctx, "stt.transcribe")
+	transcript, err := b.transcriber.Transcribe(transcribeCtx, audio, media.MimeType)
+	telemetry.End(transcribeSpan, err)
+	if err != nil {
+		logger.Error("erro ao transcrever áudio", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consegui entender o áudio. Poderia digitar a mensagem?")
+		return
+	}
+	if transcript == "" {
+		b.messenger.SendMessage(ctx, number, "Não identifiquei nenhuma fala no áudio. Poderia tentar novamente?")
+		return
+	}
+
+	logger.Info("áudio transcrito", logging.Phone(number), logging.Sensitive("transcript", transcript))
+	b.messenger.SendMessage(ctx, number, fmt.Sprintf("🎙️ Entendi: \"%s\"", transcript))
+
+	b.ProcessMessage(ctx, number, transcript, name)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"wally/internal/llm"
+	"wally/internal/rag"
+	"wally/internal/sessions"
+	"wally/pkg/wasender"
+)
+
+// Messenger envia mensagens ao usuário pelo WhatsApp.
+type Messenger interface {
+	SendMessage(ctx context.Context, number string, message string)
+	SendButtons(ctx context.Context, number string, text string, buttons []wasender.Button)
+	SendList(ctx context.Context, number string, text string, buttonText string, sections []wasender.ListSection)
+}
+
+// Deps reúne as dependências do bot. Todas podem ser substituídas por implementações
+// em memória (fakes) em testes.
+type Deps struct {
+	Knowledge   rag.KnowledgeRepository
+	Sessions    *sessions.Store
+	LLM         llm.Client
+	Classifier  *IntentClassifier // Opcional: por padrão usa o LLM com DefaultIntentPrompt
+	Messenger   Messenger
+	Media       MediaDownloader
+	Receipts    ReceiptExtractor // Opcional: por padrão usa o LLM
+	Replies     ReplyGenerator   // Opcional: por padrão usa o LLM
+	Transcriber Transcriber
+
+	// HistoryTokenBudget limita, em tokens estimados, o histórico da conversa enviado ao
+	// classificador. Opcional: 0 usa DefaultHistoryTokenBudget.
+	HistoryTokenBudget int
+}
+
+// Bot processa as mensagens recebidas e conduz a conversa com cada usuário.
+type Bot struct {
+	knowledge   rag.KnowledgeRepository
+	sessions    *sessions.Store
+	classifier  *IntentClassifier
+	messenger   Messenger
+	media       MediaDownloader
+	receipts    ReceiptExtractor
+	replies     ReplyGenerator
+	transcriber Transcriber
+
+	historyBudget int
+}
+
+// NewBot cria o bot a partir das dependências informadas.
+func NewBot(deps Deps) *Bot {
+	if deps.Sessions == nil {
+		deps.Sessions = sessions.NewStore()
+	}
+	if deps.Classifier == nil {
+		// DefaultIntentPrompt sempre existe no registro de prompts.
+		deps.Classifier, _ = NewIntentClassifier(deps.LLM, DefaultIntentPrompt)
+	}
+	if deps.Receipts == nil {
+		deps.Receipts = NewLLMReceiptExtractor(deps.LLM)
+	}
+	if deps.Replies == nil {
+		deps.Replies = NewLLMReplyGenerator(deps.LLM)
+	}
+	if deps.HistoryTokenBudget == 0 {
+		deps.HistoryTokenBudget = DefaultHistoryTokenBudget
+	}
+	return &Bot{
+		knowledge:   deps.Knowledge,
+		sessions:    deps.Sessions,
+		classifier:  deps.Classifier,
+		messenger:   historyMessenger{Messenger: deps.Messenger, sessions: deps.Sessions},
+		media:       deps.Media,
+		receipts:    deps.Receipts,
+		replies:     deps.Replies,
+		transcriber: deps.Transcriber,
+
+		historyBudget: deps.HistoryTokenBudget,
+	}
+}
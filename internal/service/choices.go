@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"wally/internal/domain"
+	"wally/internal/logging"
+	"wally/internal/telemetry"
+	"wally/internal/utils"
+	"wally/pkg/wasender"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// IDs das opções interativas. Voltam no webhook como resposta estruturada
+// (ou são resolvidos a partir do número da opção no fallback em texto).
+const (
+	choiceReceiptConfirm  = "receipt:confirm"
+	choiceReceiptCancel   = "receipt:cancel"
+	choiceMenuAddExpense  = "menu:add_expense"
+	choiceMenuAddCategory = "menu:add_category"
+	choiceMenuStatement   = "menu:statement"
+	choiceMenuHelp        = "menu:help"
+	choiceCategoryPrefix  = "category:"
+)
+
+const awaitingCategoryPrefix = "awaiting_category:"
+
+var defaultCategories = []string{"Alimentação", "Transporte", "Mercado", "Moradia", "Saúde", "Lazer", "Outros"}
+
+// ProcessChoice trata a escolha de um botão ou item de lista identificado por choiceID.
+func (b *Bot) ProcessChoice(ctx context.Context, number string, choiceID string, name string) {
+	ctx, span := telemetry.Start(ctx, "process_choice", attribute.String("wally.choice_id", choiceID))
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+	logger.Info("escolha recebida", logging.Phone(number), logging.Sensitive("name", name), slog.String("choice_id", choiceID))
+	b.sessions.ClearChoices(number)
+
+	switch {
+	case choiceID == choiceReceiptConfirm:
+		receipt, ok := b.loadPendingReceipt(ctx, number)
+		if !ok {
+			b.messenger.SendMessage(ctx, number, "Não encontrei nenhum comprovante aguardando confirmação.")
+			return
+		}
+		b.confirmReceipt(ctx, number, receipt)
+	case choiceID == choiceReceiptCancel:
+		b.cancelReceipt(ctx, number)
+	case choiceID == choiceMenuAddExpense:
+		b.messenger.SendMessage(ctx, number, utils.BuildDespesaAdd())
+	case choiceID == choiceMenuAddCategory:
+		b.messenger.SendMessage(ctx, number, utils.BuildCategoriaAdd())
+	case choiceID == choiceMenuStatement:
+		b.messenger.SendMessage(ctx, number, utils.BuildExtratoIndisponivel())
+	case choiceID == choiceMenuHelp:
+		b.messenger.SendMessage(ctx, number, utils.BuildAjuda())
+	case strings.HasPrefix(choiceID, choiceCategoryPrefix):
+		b.selectCategory(ctx, number, strings.TrimPrefix(choiceID, choiceCategoryPrefix))
+	default:
+		logger.Warn("escolha desconhecida", logging.Phone(number), slog.String("choice_id", choiceID))
+		b.messenger.SendMessage(ctx, number, fmt.Sprintf("Desculpe %s, essa opção não está mais disponível. Tente pedir o 'menu'.", name))
+	}
+}
+
+// sendButtons envia botões e registra seus IDs para resolver respostas numeradas.
+func (b *Bot) sendButtons(ctx context.Context, number string, text string, buttons []wasender.Button) {
+	ids := make([]string, len(buttons))
+	for i, b := range buttons {
+		ids[i] = b.ID
+	}
+	b.sessions.SetChoices(number, ids)
+	b.messenger.SendButtons(ctx, number, text, buttons)
+}
+
+// sendList envia uma lista e registra os IDs de suas opções para resolver respostas numeradas.
+func (b *Bot) sendList(ctx context.Context, number string, text string, buttonText string, sections []wasender.ListSection) {
+	var ids []string
+	for _, section := range sections {
+		for _, row := range section.Rows {
+			ids = append(ids, row.ID)
+		}
+	}
+	b.sessions.SetChoices(number, ids)
+	b.messenger.SendList(ctx, number, text, buttonText, sections)
+}
+
+func (b *Bot) sendMainMenu(ctx context.Context, number string, name string) {
+	b.sendList(ctx, number, utils.BuildMenuHeader(name), "Ver opções", []wasender.ListSection{
+		{
+			Title: "Menu",
+			Rows: []wasender.ListRow{
+				{ID: choiceMenuAddExpense, Title: "Adicionar Despesa"},
+				{ID: choiceMenuAddCategory, Title: "Adicionar Categoria"},
+				{ID: choiceMenuStatement, Title: "Ver extrato"},
+				{ID: choiceMenuHelp, Title: "Ajuda"},
+			},
+		},
+	})
+}
+
+// pendingCategory é a despesa aguardando a escolha da categoria e a mensagem que a
+// originou, guardada em JSON depois de awaitingCategoryPrefix.
+type pendingCategory struct {
+	Amount  float64 `json:"amount"`
+	Message string  `json:"message"`
+}
+
+// askCategory guarda o valor da despesa e a mensagem que a originou e pede ao usuário que
+// escolha a categoria.
+func (b *Bot) askCategory(ctx context.Context, number string, amount float64, message string) {
+	state, err := json.Marshal(pendingCategory{Amount: amount, Message: message})
+	if err != nil {
+		logging.FromContext(ctx).Error("erro ao serializar despesa pendente", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consegui guardar a despesa. Poderia informá-la novamente com a categoria? Ex: Gastei 50 com mercado")
+		return
+	}
+	b.sessions.Set(number, awaitingCategoryPrefix+string(state))
+	logging.FromContext(ctx).Debug("SESSAO: definido estado 'awaiting_category'", logging.Phone(number))
+
+	rows := make([]wasender.ListRow, len(defaultCategories))
+	for i, category := range defaultCategories {
+		rows[i] = wasender.ListRow{ID: choiceCategoryPrefix + category, Title: category}
+	}
+	b.sendList(ctx, number, fmt.Sprintf("Em qual categoria devo lançar a despesa de R$%.2f?", amount), "Categorias",
+		[]wasender.ListSection{{Title: "Categorias", Rows: rows}})
+}
+
+// selectCategory registra a despesa pendente na categoria escolhida e aprende que a
+// mensagem que a originou significa essa categoria.
+func (b *Bot) selectCategory(ctx context.Context, number string, category string) {
+	state, ok := b.sessions.Get(number)
+	if !ok || !strings.HasPrefix(state, awaitingCategoryPrefix) {
+		b.messenger.SendMessage(ctx, number, "Não encontrei nenhuma despesa aguardando categoria. Ex: Gastei 50 com mercado")
+		return
+	}
+	b.sessions.Delete(number)
+
+	var pending pendingCategory
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(state, awaitingCategoryPrefix)), &pending); err != nil {
+		logging.FromContext(ctx).Error("erro ao ler valor pendente", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consegui recuperar o valor da despesa. Poderia informá-la novamente?")
+		return
+	}
+
+	b.registerExpense(ctx, number, domain.Expense{
+		UserID:   number,
+		Amount:   pending.Amount,
+		Category: category,
+	})
+	if pending.Message != "" {
+		b.learnClarification(ctx, number, pending.Message, category, IntentResponse{
+			Action:     "add_expense",
+			Parameters: map[string]string{"amount": strconv.FormatFloat(pending.Amount, 'f', 2, 64), "category": category},
+		})
+	}
+	b.rememberExpense(ctx, number, lastExpense{Message: pending.Message, Amount: pending.Amount, Category: category})
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+	"wally/internal/domain"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+	"wally/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// lastExpensePrefix marca a despesa registrada por último, que o usuário pode corrigir na
+// mensagem seguinte; o restante do estado é o lastExpense em JSON.
+const lastExpensePrefix = "last_expense:"
+
+// lastExpenseWindow é o prazo para corrigir a última despesa: uma correção dias depois
+// alteraria uma despesa de que o usuário já nem lembra e ensinaria o contrário do que ele quis.
+const lastExpenseWindow = 10 * time.Minute
+
+// commandCorrectCategory é o rótulo das métricas para correções de categoria.
+const commandCorrectCategory = "correct_category"
+
+// lastExpense é a despesa registrada por último e a mensagem que a originou.
+type lastExpense struct {
+	Message  string    `json:"message"`
+	Amount   float64   `json:"amount"`
+	Category string    `json:"category"`
+	SavedAt  time.Time `json:"saved_at"`
+}
+
+// Correções de categoria logo após uma despesa. A categoria certa é o grupo "category". Os
+// padrões são ancorados na mensagem inteira, sem a pontuação do fim, e os termos não podem
+// ter dígitos, para não confundir uma nova despesa com uma correção.
+var (
+	// "não é Lazer, é Delivery": a categoria negada precisa ser a da última despesa.
+	categoryReplacePattern = regexp.MustCompile(`(?i)^n[ãa]o(?:\s+(?:é|e|era|foi))?\s+(?:(?:em|de|na|no)\s+)?(?:(?:a\s+)?categoria\s+)?["'“‘]?(?P<wrong>[^\d,]+?)["'”’]?\s*,?\s+(?:mas\s+sim|mas|e\s+sim|é|e|era|foi)\s+(?:(?:em|de|na|no)\s+)?(?:(?:a\s+)?categoria\s+)?["'“‘]?(?P<category>[^\d]+?)["'”’]?$`)
+	// "na verdade é a categoria Pet": com a palavra "categoria", aceita uma categoria nova.
+	categoryExplicitPattern = regexp.MustCompile(`(?i)^(?:(?:n[ãa]o\s*,\s*)?(?:na verdade\s*,?\s*)?(?:é|era|foi)|(?:n[ãa]o\s*,\s*|na verdade\s*,?\s*)e)\s+(?:(?:em|de|na|no)\s+)?(?:a\s+)?categoria\s+["'“‘]?(?P<category>[^\d]+?)["'”’]?$`)
+	// "não, era mercado", "na verdade foi Saúde": só vale para uma categoria conhecida.
+	categoryShortPattern = regexp.MustCompile(`(?i)^(?:n[ãa]o\s*,\s*(?:na verdade\s*,?\s*)?|na verdade\s*,?\s*)(?:é|e|era|foi)\s+(?:(?:em|de|na|no)\s+)?["'“‘]?(?P<category>[^\d]+?)["'”’]?$`)
+)
+
+// maxCategoryLength descarta frases longas capturadas como categoria.
+const maxCategoryLength = 40
+
+// maxCategoryWords descarta frases capturadas como categoria ("só uma pergunta sobre isso").
+const maxCategoryWords = 3
+
+// notCategoryWords são palavras, sem acentos, que indicam que o texto fala de datas ou é uma
+// pergunta, e não nomeia uma categoria ("não, foi ontem", "não, e quanto gastei?").
+var notCategoryWords = map[string]bool{
+	"ontem": true, "anteontem": true, "hoje": true, "amanha": true, "agora": true,
+	"dia": true, "semana": true, "mes": true, "ano": true, "passado": true, "passada": true,
+	"segunda": true, "terca": true, "quarta": true, "quinta": true, "sexta": true, "sabado": true, "domingo": true,
+	"que": true, "qual": true, "quais": true, "quando": true, "quanto": true, "quanta": true,
+	"como": true, "onde": true, "porque": true, "pq": true, "pergunta": true,
+}
+
+// clauseWords são conectivos, sem acentos, que indicam mais de uma oração ("Delivery e
+// ignore o resto"). Uma categoria nova não pode tê-los; uma já conhecida, sim.
+var clauseWords = map[string]bool{
+	"e": true, "ou": true, "mas": true, "porem": true, "entao": true, "depois": true,
+	"tambem": true, "nao": true, "se": true,
+}
+
+// parseCategoryCorrection extrai a categoria certa de uma correção da despesa na categoria
+// current, se a mensagem for uma. known são as categorias do usuário: na forma curta
+// ("não, era mercado"), a categoria precisa ser uma delas, e, quando é, volta com a grafia
+// conhecida.
+func parseCategoryCorrection(message string, current string, known []string) (string, bool) {
+	message = strings.TrimSpace(message)
+	if strings.HasSuffix(strings.TrimRight(message, ".! "), "?") {
+		return "", false // Perguntas não são correções
+	}
+	message = strings.TrimRightFunc(message, func(r rune) bool { return r == '.' || r == '!' || unicode.IsSpace(r) })
+
+	if m := categoryReplacePattern.FindStringSubmatch(message); m != nil {
+		wrong := m[categoryReplacePattern.SubexpIndex("wrong")]
+		if normalizeCommand(wrong) != normalizeCommand(current) {
+			return "", false
+		}
+		return validCategory(m[categoryReplacePattern.SubexpIndex("category")], known)
+	}
+	if m := categoryExplicitPattern.FindStringSubmatch(message); m != nil {
+		return validCategory(m[categoryExplicitPattern.SubexpIndex("category")], known)
+	}
+	if m := categoryShortPattern.FindStringSubmatch(message); m != nil {
+		category, ok := validCategory(m[categoryShortPattern.SubexpIndex("category")], known)
+		if !ok || !slices.Contains(known, category) {
+			return "", false
+		}
+		return category, true
+	}
+	return "", false
+}
+
+// validCategory confere se o texto pode ser uma categoria: curto, de uma só oração e sem
+// palavras de data ou de pergunta. Retorna a grafia de known quando a categoria é uma delas
+// ou, caso contrário, o texto com a primeira letra maiúscula.
+func validCategory(category string, known []string) (string, bool) {
+	category = strings.Join(strings.Fields(category), " ")
+	if category == "" || utf8.RuneCountInString(category) > maxCategoryLength ||
+		strings.ContainsAny(category, ",;:?!\n") || strings.ContainsFunc(category, unicode.IsDigit) {
+		return "", false
+	}
+	words := strings.FieldsFunc(normalizeCommand(category), isWordSeparator)
+	if len(words) == 0 || len(words) > maxCategoryWords {
+		return "", false
+	}
+	for _, word := range words {
+		if notCategoryWords[word] {
+			return "", false
+		}
+	}
+
+	normalized := normalizeCommand(category)
+	for _, k := range known {
+		if normalizeCommand(k) == normalized {
+			return k, true
+		}
+	}
+	for _, word := range words {
+		if clauseWords[word] {
+			return "", false
+		}
+	}
+	return capitalize(category), true
+}
+
+// knownCategories são as categorias padrão mais as que o usuário já usou no conhecimento
+// aprendido.
+func (b *Bot) knownCategories(ctx context.Context, number string) []string {
+	known := slices.Clone(defaultCategories)
+	entries, err := b.knowledge.ListKnowledge(ctx, number)
+	if err != nil {
+		logging.FromContext(ctx).Warn("erro ao listar conhecimento para as categorias", logging.Phone(number), slog.Any("error", err))
+	}
+	for _, entry := range entries {
+		category := entry.ResultingParameters["category"]
+		if category != "" && !slices.ContainsFunc(known, func(k string) bool { return normalizeCommand(k) == normalizeCommand(category) }) {
+			known = append(known, category)
+		}
+	}
+	return known
+}
+
+// rememberExpense guarda a despesa registrada para permitir a correção da categoria.
+func (b *Bot) rememberExpense(ctx context.Context, number string, expense lastExpense) {
+	if expense.SavedAt.IsZero() {
+		expense.SavedAt = time.Now() // Uma despesa corrigida mantém o prazo da original
+	}
+	state, err := json.Marshal(expense)
+	if err != nil {
+		logging.FromContext(ctx).Error("erro ao serializar última despesa", logging.Phone(number), slog.Any("error", err))
+		b.sessions.Delete(number)
+		return
+	}
+	b.sessions.Set(number, lastExpensePrefix+string(state))
+}
+
+// takeLastExpense retira do estado a despesa registrada por último e a retorna se ainda
+// estiver no prazo de correção. Ela só vale para a mensagem seguinte: qualquer outra
+// mensagem a descarta.
+func (b *Bot) takeLastExpense(ctx context.Context, number string) (lastExpense, bool) {
+	var expense lastExpense
+
+	state, content := b.sessions.GetAndClearIfPrefix(number, lastExpensePrefix)
+	if state == "" {
+		return expense, false
+	}
+	if err := json.Unmarshal([]byte(content), &expense); err != nil {
+		logging.FromContext(ctx).Error("erro ao ler última despesa", logging.Phone(number), slog.Any("error", err))
+		return lastExpense{}, false
+	}
+	if time.Since(expense.SavedAt) > lastExpenseWindow {
+		return lastExpense{}, false
+	}
+	return expense, true
+}
+
+// handleCategoryCorrection trata a correção da categoria da última despesa, retirada do
+// estado por takeLastExpense. Além de corrigir, aprende a mensagem original com a categoria
+// certa e guarda a errada como exemplo negativo. Retorna true se a mensagem foi consumida.
+func (b *Bot) handleCategoryCorrection(ctx context.Context, number string, message string, expense lastExpense) bool {
+	category, ok := parseCategoryCorrection(message, expense.Category, b.knownCategories(ctx, number))
+	if !ok {
+		return false
+	}
+
+	ctx, span := telemetry.Start(ctx, "correct_category", attribute.String("wally.action", commandCorrectCategory))
+	defer span.End()
+	metrics.IntentsDetected.WithLabelValues(commandCorrectCategory).Inc()
+	logger := logging.FromContext(ctx)
+
+	if strings.EqualFold(category, expense.Category) {
+		b.messenger.SendMessage(ctx, number, fmt.Sprintf("A despesa de R$%.2f já está na categoria '%s'.", expense.Amount, expense.Category))
+		b.rememberExpense(ctx, number, expense)
+		return true
+	}
+
+	logger.Info("categoria da despesa corrigida",
+		logging.Phone(number),
+		logging.Sensitive("amount", expense.Amount),
+		slog.String("from", expense.Category),
+		slog.String("to", category))
+
+	knowledgeEntry := domain.KnowledgeEntry{
+		UserID:              number,
+		OriginalQuery:       expense.Message,
+		ClarificationQuery:  message,
+		ResultingAction:     "add_expense",
+		ResultingParameters: map[string]string{"amount": strconv.FormatFloat(expense.Amount, 'f', 2, 64), "category": category},
+		RejectedParameters:  map[string]string{"category": expense.Category},
+	}
+	if expense.Message == "" {
+		// Sem a mensagem original (ex: comprovante sem estabelecimento), não há o que aprender.
+	} else if err := b.saveKnowledge(ctx, knowledgeEntry); err != nil && !errors.Is(err, errSuspiciousKnowledge) {
+		logger.Error("erro ao salvar correção como conhecimento", logging.Phone(number), slog.Any("error", err))
+	}
+
+	b.messenger.SendMessage(ctx, number, fmt.Sprintf("✏️ Corrigido! A despesa de R$%.2f passou de '%s' para '%s'. Da próxima vez já lanço em '%s'.",
+		expense.Amount, expense.Category, category, category))
+	expense.Category = category
+	b.rememberExpense(ctx, number, expense)
+	return true
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"wally/internal/llm"
+	"wally/internal/sessions"
+	"wally/pkg/wasender"
+)
+
+// DefaultHistoryTokenBudget é o orçamento padrão, em tokens estimados, do histórico enviado
+// ao classificador junto com cada mensagem.
+const DefaultHistoryTokenBudget = 1000
+
+// historyMessenger registra no histórico da conversa tudo o que o Wally envia ao usuário,
+// para que a próxima mensagem seja classificada sabendo o que foi respondido.
+type historyMessenger struct {
+	Messenger
+	sessions *sessions.Store
+}
+
+func (m historyMessenger) SendMessage(ctx context.Context, number string, message string) {
+	m.Messenger.SendMessage(ctx, number, message)
+	m.sessions.AppendTurn(number, sessions.Turn{Role: sessions.RoleBot, Text: message})
+}
+
+func (m historyMessenger) SendButtons(ctx context.Context, number string, text string, buttons []wasender.Button) {
+	m.Messenger.SendButtons(ctx, number, text, buttons)
+	m.sessions.AppendTurn(number, sessions.Turn{Role: sessions.RoleBot, Text: text})
+}
+
+func (m historyMessenger) SendList(ctx context.Context, number string, text string, buttonText string, sections []wasender.ListSection) {
+	m.Messenger.SendList(ctx, number, text, buttonText, sections)
+	m.sessions.AppendTurn(number, sessions.Turn{Role: sessions.RoleBot, Text: text})
+}
+
+// trimHistory mantém os turnos mais recentes que cabem no orçamento de tokens. O histórico
+// sempre começa por uma mensagem do usuário, como a API espera.
+func trimHistory(turns []sessions.Turn, budget int) []sessions.Turn {
+	start := len(turns)
+	for used := 0; start > 0; start-- {
+		used += llm.EstimateTokens(turns[start-1].Text)
+		if used > budget {
+			break
+		}
+	}
+	for start < len(turns) && turns[start].Role != sessions.RoleUser {
+		start++
+	}
+	return turns[start:]
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"wally/internal/domain"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+	"wally/internal/rag"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// injectionPatterns são indícios de tentativas de prompt injection, aplicados ao texto
+// normalizado por normalizeCommand (minúsculas e sem acentos). Servem para registrar e
+// conter textos suspeitos, não para bloquear mensagens: a defesa principal é o prompt (v2 em diante).
+var injectionPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"ignore_instructions", regexp.MustCompile(`\b(ignor[ae]r?|desconsider[ae]r?|esquec[ae]r?|ignore|disregard|forget)\b.{0,30}\b(instruc\w*|regras|prompt|instructions?|rules)\b`)},
+	{"role_override", regexp.MustCompile(`\b(voce agora e|a partir de agora voce|aja como|finja (que|ser)|you are now|act as|pretend to be)\b`)},
+	{"prompt_leak", regexp.MustCompile(`\b(system prompt|prompt do sistema|(mostre|revele|repita)( o| seu| suas)? (prompt|instrucoes)|reveal your (prompt|instructions))\b`)},
+	{"markup", regexp.MustCompile("(</?(system|instructions?|prompt)>|\\[/?(inst|system)\\]|```)")},
+	{"json_forgery", regexp.MustCompile(`"(action|parameters)"\s*:`)},
+}
+
+// errSuspiciousKnowledge indica que o conhecimento não foi salvo por parecer prompt injection.
+var errSuspiciousKnowledge = errors.New("conhecimento com indícios de prompt injection")
+
+// suspiciousPatterns retorna os nomes dos padrões de prompt injection encontrados no texto.
+func suspiciousPatterns(text string) []string {
+	normalized := normalizeCommand(text)
+	var found []string
+	for _, p := range injectionPatterns {
+		if p.pattern.MatchString(normalized) {
+			found = append(found, p.name)
+		}
+	}
+	return found
+}
+
+// flagSuspicious registra nos logs, nas métricas e no span os textos com indícios de prompt
+// injection e informa se o texto é suspeito. source identifica a origem ("message",
+// "knowledge").
+func flagSuspicious(ctx context.Context, number string, source string, text string) bool {
+	patterns := suspiciousPatterns(text)
+	if len(patterns) == 0 {
+		return false
+	}
+	for _, pattern := range patterns {
+		metrics.SuspiciousInputs.WithLabelValues(source, pattern).Inc()
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.StringSlice("wally.suspicious_patterns", patterns))
+	logging.FromContext(ctx).Warn("texto com indícios de prompt injection",
+		logging.Phone(number),
+		slog.String("source", source),
+		slog.Any("patterns", patterns),
+		logging.Sensitive("text", text))
+	return true
+}
+
+// knowledgeText é o texto da entrada verificado por flagSuspicious: tudo o que vai para o
+// prompt, inclusive a categoria, que o usuário pode escrever livremente.
+func knowledgeText(entry domain.KnowledgeEntry) string {
+	return entry.OriginalQuery + "\n" + entry.ClarificationQuery + "\n" + entry.ResultingParameters["category"]
+}
+
+// saveKnowledge salva a entrada, recusando textos suspeitos para que uma instrução
+// maliciosa não passe a ser enviada ao LLM em todas as mensagens seguintes.
+func (b *Bot) saveKnowledge(ctx context.Context, entry domain.KnowledgeEntry) error {
+	if flagSuspicious(ctx, entry.UserID, "knowledge", knowledgeText(entry)) {
+		return errSuspiciousKnowledge
+	}
+	return b.knowledge.SaveKnowledge(ctx, entry)
+}
+
+// dropSuspiciousKnowledge remove do resultado da recuperação as entradas suspeitas, salvas
+// antes da verificação existir, e remonta o contexto.
+func dropSuspiciousKnowledge(ctx context.Context, number string, learned rag.Knowledge) rag.Knowledge {
+	var kept []domain.KnowledgeEntry
+	for _, entry := range learned.Entries {
+		if !flagSuspicious(ctx, number, "knowledge", knowledgeText(entry)) {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(learned.Entries) {
+		return learned
+	}
+	return rag.Knowledge{Entries: kept, Context: rag.BuildContext(kept)}
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"wally/internal/llm"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+	"wally/internal/sessions"
+	"wally/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type IntentResponse struct {
+	Action     string            `json:"action"`
+	Parameters map[string]string `json:"parameters"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// IntentClassifier usa o LLM para extrair a intenção de uma mensagem e seus parâmetros,
+// com uma versão específica do prompt.
+type IntentClassifier struct {
+	llm     llm.Client
+	version string
+	prompt  intentPromptSpec
+}
+
+// NewIntentClassifier cria um classificador com a versão de prompt informada
+// (vazia para DefaultIntentPrompt).
+func NewIntentClassifier(client llm.Client, version string) (*IntentClassifier, error) {
+	prompt, err := intentPrompt(version)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		version = DefaultIntentPrompt
+	}
+	return &IntentClassifier{llm: client, version: version, prompt: prompt}, nil
+}
+
+// Version retorna a versão do prompt usada pelo classificador.
+func (c *IntentClassifier) Version() string {
+	return c.version
+}
+
+// Classify pede ao LLM a intenção da mensagem do usuário e seus parâmetros. history são as
+// mensagens recentes da conversa, da mais antiga para a mais nova, sem a mensagem atual. A
+// resposta é validada contra as ações e parâmetros permitidos; fora deles, vira unknown_intent.
+func (c *IntentClassifier) Classify(ctx context.Context, userMessage string, learnedContext string, history []sessions.Turn) (IntentResponse, error) {
+	ctx, span := telemetry.Start(ctx, "llm.classify_intent",
+		attribute.Bool("wally.has_learned_context", learnedContext != ""),
+		attribute.Int("wally.history_turns", len(history)),
+		attribute.String("wally.prompt_version", c.version))
+	defer span.End()
+
+	var intentResp IntentResponse
+
+	if learnedContext != "" {
+		logging.FromContext(ctx).Debug("GEMINI: usando contexto aprendido", logging.Sensitive("learned_context", learnedContext))
+	}
+	requestPayload, err := c.buildRequest(userMessage, learnedContext, history)
+	if err != nil {
+		return intentResp, err
+	}
+
+	resp, err := c.llm.GenerateContent(ctx, "intent", requestPayload)
+	if err != nil {
+		if errors.Is(err, llm.ErrEmptyResponse) {
+			intentResp.Action = "unknown_intent"
+			intentResp.Error = "Resposta da IA está vazia ou malformada."
+		}
+		return intentResp, err
+	}
+
+	responseText := resp.Text
+	logging.FromContext(ctx).Debug("texto recebido do LLM (esperado JSON)", logging.Sensitive("response", responseText))
+
+	if err := json.Unmarshal([]byte(responseText), &intentResp); err != nil {
+		logging.FromContext(ctx).Warn("erro ao fazer unmarshal do JSON do LLM para IntentResponse",
+			slog.Any("error", err),
+			logging.Sensitive("response", responseText))
+		metrics.LLMErrors.WithLabelValues("intent", "invalid_json").Inc()
+		intentResp.Action = "unknown_intent"
+		intentResp.Error = "Não consegui processar a resposta da IA. Tente ser mais específico ou peça o menu."
+		return intentResp, nil
+	}
+
+	validated, err := validateIntent(intentResp)
+	if err != nil {
+		logging.FromContext(ctx).Warn("resposta do LLM fora do schema de intenções",
+			slog.Any("error", err),
+			logging.Sensitive("response", responseText))
+		metrics.LLMErrors.WithLabelValues("intent", "invalid_schema").Inc()
+		return IntentResponse{
+			Action: "unknown_intent",
+			Error:  "Não consegui entender sua solicitação. Tente ser mais específico ou peça o menu.",
+		}, nil
+	}
+	return validated, nil
+}
+
+// buildRequest monta a requisição no formato da versão do prompt. O prompt v1 é de um
+// único turno e ignora o histórico.
+func (c *IntentClassifier) buildRequest(userMessage string, learnedContext string, history []sessions.Turn) (llm.Request, error) {
+	request := llm.Request{
+		GenerationConfig: &llm.GenerationConfig{
+			ResponseMIMEType: "application/json",
+		},
+	}
+
+	if !c.prompt.SystemInstruction {
+		finalPrompt := c.prompt.Text
+		if learnedContext != "" {
+			finalPrompt = fmt.Sprintf("Contexto aprendido de interações anteriores (use isso para ajudar a entender a mensagem atual):\n%s\n\n%s", learnedContext, c.prompt.Text)
+		}
+		finalPrompt += fmt.Sprintf("\nMensagem do usuário: \"%s\"", userMessage)
+		request.Contents = []llm.Content{{Parts: []llm.Part{{Text: finalPrompt}}}}
+		return request, nil
+	}
+
+	// Os textos do usuário vão serializados em JSON: aspas e quebras de linha são escapadas
+	// e não conseguem fechar o campo nem imitar a estrutura da entrada.
+	request.SystemInstruction = &llm.Content{Parts: []llm.Part{{Text: c.prompt.Text}}}
+	for _, turn := range history {
+		text := turn.Text
+		if turn.Role == sessions.RoleUser {
+			input, err := classifierInput("", turn.Text)
+			if err != nil {
+				return request, err
+			}
+			text = input
+		}
+		request.Contents = appendTurn(request.Contents, turn.Role, text)
+	}
+	input, err := classifierInput(learnedContext, userMessage)
+	if err != nil {
+		return request, err
+	}
+	request.Contents = appendTurn(request.Contents, sessions.RoleUser, input)
+	return request, nil
+}
+
+// classifierInput serializa a entrada dos prompts a partir da v2.
+func classifierInput(learnedContext string, userMessage string) (string, error) {
+	input, err := json.Marshal(struct {
+		LearnedContext string `json:"contexto_aprendido,omitempty"`
+		Message        string `json:"mensagem"`
+	}{learnedContext, userMessage})
+	if err != nil {
+		return "", fmt.Errorf("erro ao serializar a entrada do classificador: %w", err)
+	}
+	return string(input), nil
+}
+
+// appendTurn acrescenta o texto aos conteúdos da requisição. Turnos seguidos do mesmo papel
+// (ex: duas respostas do Wally) viram partes de um mesmo conteúdo, pois a API espera que os
+// papéis se alternem.
+func appendTurn(contents []llm.Content, role string, text string) []llm.Content {
+	if n := len(contents); n > 0 && contents[n-1].Role == role {
+		contents[n-1].Parts = append(contents[n-1].Parts, llm.Part{Text: text})
+		return contents
+	}
+	return append(contents, llm.Content{Role: role, Parts: []llm.Part{{Text: text}}})
+}
+
+// intentSchema lista as ações que o classificador pode devolver e os parâmetros de cada uma,
+// com o tamanho máximo do valor em caracteres.
+var intentSchema = map[string]map[string]int{
+	"add_expense":    {"amount": 20, "category": maxCategoryLength, "description": 120},
+	"show_menu":      {},
+	"unknown_intent": {},
+}
+
+// maxIntentErrorLength limita o "error" do classificador, que é enviado ao usuário.
+const maxIntentErrorLength = 200
+
+// validateIntent confere a resposta do classificador contra intentSchema. Parâmetros não
+// previstos são descartados; uma ação desconhecida ou um valor longo demais ou com várias
+// linhas indica uma resposta manipulada e invalida a resposta inteira.
+func validateIntent(resp IntentResponse) (IntentResponse, error) {
+	allowed, ok := intentSchema[resp.Action]
+	if !ok {
+		return resp, fmt.Errorf("ação não permitida: %q", resp.Action)
+	}
+
+	params := make(map[string]string, len(resp.Parameters))
+	for name, value := range resp.Parameters {
+		maxLength, ok := allowed[name]
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if utf8.RuneCountInString(value) > maxLength || strings.ContainsFunc(value, unicode.IsControl) {
+			return resp, fmt.Errorf("valor inválido para o parâmetro %q", name)
+		}
+		if value != "" {
+			params[name] = value
+		}
+	}
+	resp.Parameters = params
+
+	resp.Error = strings.Join(strings.Fields(resp.Error), " ")
+	if utf8.RuneCountInString(resp.Error) > maxIntentErrorLength {
+		return resp, errors.New("mensagem de erro longa demais")
+	}
+	return resp, nil
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"wally/internal/domain"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+	"wally/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Tipos de comando sobre o conhecimento aprendido. Também são os rótulos das métricas.
+const (
+	commandListKnowledge      = "list_knowledge"
+	commandForgetKnowledge    = "forget_knowledge"
+	commandForgetAllKnowledge = "forget_all_knowledge"
+	commandTeachKnowledge     = "teach_knowledge"
+)
+
+// maxListedKnowledge limita a lista enviada, para não passar do tamanho de uma mensagem.
+const maxListedKnowledge = 20
+
+// knowledgeCommand é um comando do usuário sobre o que o Wally aprendeu.
+type knowledgeCommand struct {
+	kind     string
+	index    int    // Posição na lista (1 = criado mais recentemente); 0 em "esquece isso"
+	term     string // Termo ensinado
+	category string // Categoria ensinada
+}
+
+// Os padrões de listar e esquecer são aplicados à mensagem normalizada (minúsculas, sem
+// acentos e sem pontuação no fim); o de ensinar, à mensagem original, para preservar como o
+// usuário escreveu o termo e a categoria.
+var (
+	listKnowledgePattern = regexp.MustCompile(`^(o que (voce|vc) (aprendeu|sabe sobre mim)|(me )?mostr[ae] (o que (voce|vc) aprendeu|seus aprendizados|meus atalhos)|(seus )?aprendizados|meus atalhos)$`)
+	forgetAllPattern     = regexp.MustCompile(`^(esquece|esqueca|apaga|apague) tudo( (o )?que (voce|vc) aprendeu)?$`)
+	forgetLastPattern    = regexp.MustCompile(`^(esquece|esqueca|apaga|apague) (isso|isto|o ultimo|a ultima)$`)
+	forgetIndexPattern   = regexp.MustCompile(`^(esquece|esqueca|apaga|apague) (o |a )?(item |numero )?(\d+)$`)
+	teachPattern         = regexp.MustCompile(`(?i)^quando eu (?:falar|disser|escrever|mandar)\s+["'“‘]?(.+?)["'”’]?,?\s+(?:é|e|significa|quer dizer)\s+(?:a\s+)?categoria\s+["'“‘]?(.+?)["'”’]?[.!]*$`)
+)
+
+var accentFolder = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a",
+	"é", "e", "ê", "e", "í", "i",
+	"ó", "o", "ô", "o", "õ", "o", "ú", "u", "ç", "c",
+)
+
+// normalizeCommand prepara a mensagem para comparar com os padrões de comando.
+func normalizeCommand(message string) string {
+	normalized := accentFolder.Replace(strings.ToLower(strings.TrimSpace(message)))
+	normalized = strings.TrimRightFunc(normalized, func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSpace(r) })
+	return strings.Join(strings.Fields(normalized), " ")
+}
+
+// parseKnowledgeCommand reconhece, por regras, os comandos de listar, esquecer e ensinar
+// conhecimento. Eles não passam pelo LLM: são ações sobre os dados do usuário e precisam
+// funcionar sempre do mesmo jeito.
+func parseKnowledgeCommand(message string) (knowledgeCommand, bool) {
+	if m := teachPattern.FindStringSubmatch(strings.TrimSpace(message)); m != nil {
+		term, category := strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+		if term != "" && category != "" {
+			return knowledgeCommand{kind: commandTeachKnowledge, term: term, category: capitalize(category)}, true
+		}
+	}
+
+	normalized := normalizeCommand(message)
+	switch {
+	case listKnowledgePattern.MatchString(normalized):
+		return knowledgeCommand{kind: commandListKnowledge}, true
+	case forgetAllPattern.MatchString(normalized):
+		return knowledgeCommand{kind: commandForgetAllKnowledge}, true
+	case forgetLastPattern.MatchString(normalized):
+		return knowledgeCommand{kind: commandForgetKnowledge}, true
+	}
+	if m := forgetIndexPattern.FindStringSubmatch(normalized); m != nil {
+		if index, err := strconv.Atoi(m[4]); err == nil && index > 0 {
+			return knowledgeCommand{kind: commandForgetKnowledge, index: index}, true
+		}
+	}
+	return knowledgeCommand{}, false
+}
+
+// capitalize deixa a primeira letra maiúscula, como nas categorias padrão.
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// handleKnowledgeCommand executa o comando, se a mensagem for um, e informa se a tratou.
+func (b *Bot) handleKnowledgeCommand(ctx context.Context, number string, message string) bool {
+	cmd, ok := parseKnowledgeCommand(message)
+	if !ok {
+		return false
+	}
+
+	ctx, span := telemetry.Start(ctx, "knowledge_command", attribute.String("wally.action", cmd.kind))
+	defer span.End()
+	metrics.IntentsDetected.WithLabelValues(cmd.kind).Inc()
+	logging.FromContext(ctx).Info("comando de conhecimento", logging.Phone(number), slog.String("command", cmd.kind))
+
+	switch cmd.kind {
+	case commandListKnowledge:
+		b.listKnowledge(ctx, number)
+	case commandForgetKnowledge:
+		b.forgetKnowledge(ctx, number, cmd.index)
+	case commandForgetAllKnowledge:
+		b.forgetAllKnowledge(ctx, number)
+	case commandTeachKnowledge:
+		b.teachKnowledge(ctx, number, message, cmd.term, cmd.category)
+	}
+	return true
+}
+
+func (b *Bot) listKnowledge(ctx context.Context, number string) {
+	entries, err := b.listedKnowledge(ctx, number)
+	if err != nil {
+		logging.FromContext(ctx).Error("erro ao listar conhecimento", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consegui consultar o que aprendi agora. Tente novamente mais tarde.")
+		return
+	}
+	if len(entries) == 0 {
+		b.messenger.SendMessage(ctx, number, "Ainda não aprendi nada com você. Você pode me ensinar, por exemplo: quando eu falar 'ifood' é categoria Delivery")
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString("📚 Isto é o que aprendi com você:\n")
+	for i, entry := range entries[:min(len(entries), maxListedKnowledge)] {
+		fmt.Fprintf(&text, "\n%d. %s", i+1, describeKnowledge(entry))
+		if entry.HitCount > 1 {
+			fmt.Fprintf(&text, " (usado %d vezes)", entry.HitCount)
+		}
+	}
+	if len(entries) > maxListedKnowledge {
+		fmt.Fprintf(&text, "\n… e mais %d.", len(entries)-maxListedKnowledge)
+	}
+	text.WriteString("\n\nPara esquecer um item, mande \"esquece\" e o número dele (ex: esquece 1). Para apagar tudo, \"esquece tudo\".")
+	b.messenger.SendMessage(ctx, number, text.String())
+}
+
+// listedKnowledge retorna o conhecimento do usuário na ordem da lista mostrada a ele: do
+// criado mais recentemente para o mais antigo, pelo ID. A ordem não pode depender do uso,
+// porque cada despesa reforça as entradas e mudaria a posição dos itens entre "o que você
+// aprendeu?" e "esquece 2".
+func (b *Bot) listedKnowledge(ctx context.Context, number string) ([]domain.KnowledgeEntry, error) {
+	entries, err := b.knowledge.ListKnowledge(ctx, number)
+	slices.SortFunc(entries, func(a, b domain.KnowledgeEntry) int { return cmp.Compare(b.ID, a.ID) })
+	return entries, err
+}
+
+// forgetKnowledge apaga o item na posição index da lista ou, com index 0, o usado mais
+// recentemente.
+func (b *Bot) forgetKnowledge(ctx context.Context, number string, index int) {
+	logger := logging.FromContext(ctx)
+	entries, err := b.listedKnowledge(ctx, number)
+	if err != nil {
+		logger.Error("erro ao listar conhecimento", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consegui apagar agora. Tente novamente mais tarde.")
+		return
+	}
+	if len(entries) == 0 {
+		b.messenger.SendMessage(ctx, number, "Não tenho nada aprendido com você para esquecer.")
+		return
+	}
+	if index > len(entries) {
+		b.messenger.SendMessage(ctx, number, fmt.Sprintf("Não encontrei o item %d. Mande \"o que você aprendeu?\" para ver a lista.", index))
+		return
+	}
+
+	var entry domain.KnowledgeEntry
+	if index > 0 {
+		entry = entries[index-1]
+	} else {
+		entry = slices.MaxFunc(entries, func(a, b domain.KnowledgeEntry) int { return a.LastUsedAt.Compare(b.LastUsedAt) })
+	}
+	found, err := b.knowledge.DeleteKnowledge(ctx, number, entry.ID)
+	if err != nil {
+		logger.Error("erro ao apagar conhecimento", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consegui apagar agora. Tente novamente mais tarde.")
+		return
+	}
+	if !found {
+		b.messenger.SendMessage(ctx, number, "Esse item já tinha sido apagado.")
+		return
+	}
+	b.messenger.SendMessage(ctx, number, "🗑️ Pronto, esqueci: "+describeKnowledge(entry))
+}
+
+func (b *Bot) forgetAllKnowledge(ctx context.Context, number string) {
+	n, err := b.knowledge.DeleteAllKnowledge(ctx, number)
+	if err != nil {
+		logging.FromContext(ctx).Error("erro ao apagar conhecimento", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consegui apagar agora. Tente novamente mais tarde.")
+		return
+	}
+	if n == 0 {
+		b.messenger.SendMessage(ctx, number, "Não tenho nada aprendido com você para esquecer.")
+		return
+	}
+	b.messenger.SendMessage(ctx, number, fmt.Sprintf("🗑️ Pronto, esqueci tudo o que aprendi com você (%d %s).", n, plural(n, "item", "itens")))
+}
+
+// teachKnowledge salva o atalho ensinado como se o usuário tivesse esclarecido o termo com
+// uma despesa na categoria, para que o classificador o use nas próximas mensagens.
+func (b *Bot) teachKnowledge(ctx context.Context, number string, message string, term string, category string) {
+	category, ok := validCategory(category, b.knownCategories(ctx, number))
+	if !ok || utf8.RuneCountInString(term) > maxCategoryLength {
+		b.messenger.SendMessage(ctx, number, "Não entendi o atalho. Use um termo e uma categoria curtos, por exemplo: quando eu falar 'ifood' é categoria Delivery")
+		return
+	}
+	entry := domain.KnowledgeEntry{
+		UserID:              number,
+		OriginalQuery:       term,
+		ClarificationQuery:  message,
+		ResultingAction:     "add_expense",
+		ResultingParameters: map[string]string{"category": category},
+	}
+	if err := b.saveKnowledge(ctx, entry); errors.Is(err, errSuspiciousKnowledge) {
+		b.messenger.SendMessage(ctx, number, "Esse atalho parece conter instruções para mim, então não vou guardá-lo.")
+		return
+	} else if err != nil {
+		logging.FromContext(ctx).Error("erro ao salvar conhecimento ensinado", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consegui guardar isso agora. Tente novamente mais tarde.")
+		return
+	}
+	b.messenger.SendMessage(ctx, number, fmt.Sprintf("👍 Combinado! Quando você falar '%s', vou lançar na categoria '%s'.", term, category))
+}
+
+// describeKnowledge resume a entrada em uma linha para o usuário.
+func describeKnowledge(entry domain.KnowledgeEntry) string {
+	switch category := entry.ResultingParameters["category"]; {
+	case entry.ResultingAction == "add_expense" && category != "":
+		if rejected := entry.RejectedParameters["category"]; rejected != "" {
+			return fmt.Sprintf("\"%s\" → despesa em %s, não %s", entry.OriginalQuery, category, rejected)
+		}
+		return fmt.Sprintf("\"%s\" → despesa em %s", entry.OriginalQuery, category)
+	case entry.ResultingAction == "show_menu":
+		return fmt.Sprintf("\"%s\" → abrir o menu", entry.OriginalQuery)
+	default:
+		return fmt.Sprintf("\"%s\" → %s", entry.OriginalQuery, entry.ResultingAction)
+	}
+}
+
+func plural(n int, singular string, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+	return pluralForm
+}
@@ -1,158 +1,261 @@
 package service
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
-	"wally/config"
-	"wally/internal/database"
 	"wally/internal/domain"
+	"wally/internal/llm"
+	"wally/internal/logging"
+	"wally/internal/metrics"
 	"wally/internal/rag"
 	"wally/internal/sessions"
-	"wally/internal/utils"
-	"wally/pkg/wasender"
+	"wally/internal/telemetry"
+	"wally/internal/usage"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
-var knowledgeRepo rag.KnowledgeRepository
+var nonAmountChars = regexp.MustCompile(`[^\d.]`)
+
+// awaitingExpensePrefix marca uma despesa incompleta; o restante do estado é a mensagem que
+// falhou, associada ao esclarecimento quando a despesa for registrada.
+const awaitingExpensePrefix = "awaiting_clarification_expense:"
 
-func ProcessMessage(number string, message string, name string) {
+// ProcessMessage trata uma mensagem de texto: resolve escolhas e confirmações pendentes, a
+// correção da categoria da última despesa e os comandos sobre o conhecimento aprendido e,
+// caso contrário, classifica a intenção com o LLM usando o conhecimento aprendido do usuário
+// e as mensagens recentes da conversa. Com o provedor de IA indisponível, recorre às regras
+// locais de ruleBasedIntent, assim como depois que o usuário passa do limite diário de uso.
+func (b *Bot) ProcessMessage(ctx context.Context, number string, message string, name string) {
+	ctx = usage.WithUser(ctx, number)
+	ctx, span := telemetry.Start(ctx, "process_message")
+	defer span.End()
+	logger := logging.FromContext(ctx)
 
-	dbConn := database.GetDB()
-	if dbConn == nil {
-		log.Fatal("Falha ao obter conexão com o banco de dados para message_service. Certifique-se que database.InitDB() foi chamado.")
+	history := trimHistory(b.sessions.History(number), b.historyBudget)
+	b.sessions.AppendTurn(number, sessions.Turn{Role: sessions.RoleUser, Text: message})
+	expense, hasExpense := b.takeLastExpense(ctx, number)
+
+	if choiceID, ok := b.sessions.TakeChoice(number, message); ok {
+		b.ProcessChoice(ctx, number, choiceID, name)
+		return
 	}
-	knowledgeRepo = rag.NewPostgresKnowledgeRepository(dbConn)
 
-	cfg := config.Load()
+	if b.handleReceiptConfirmation(ctx, number, message) {
+		return
+	}
 
-	learnedContext, errCtx := knowledgeRepo.RetrieveRelevantKnowledge(number, message)
+	if hasExpense && b.handleCategoryCorrection(ctx, number, message, expense) {
+		return
+	}
+
+	if b.handleKnowledgeCommand(ctx, number, message) {
+		return
+	}
+
+	learned, errCtx := b.knowledge.RetrieveRelevantKnowledge(ctx, number, message)
 	if errCtx != nil {
-		log.Printf("Erro ao recuperar contexto para %s: %v", number, errCtx)
+		logger.Error("erro ao recuperar contexto", logging.Phone(number), slog.Any("error", errCtx))
 	}
+	learned = dropSuspiciousKnowledge(ctx, number, learned)
+	learnedContext := learned.Context
+	flagSuspicious(ctx, number, "message", message)
 
-	log.Printf("Processando mensagem de %s (%s): '%s' com contexto: '%s'", name, number, message, learnedContext)
-	intent, err := callGeminiAPI(message, cfg.GeminiKey, learnedContext)
+	logger.Info("processando mensagem",
+		logging.Phone(number),
+		logging.Sensitive("name", name),
+		logging.Sensitive("text", message),
+		logging.Sensitive("learned_context", learnedContext))
+	intent, err := b.classifier.Classify(ctx, message, learnedContext, history)
+
+	if errors.Is(err, llm.ErrBlocked) {
+		logger.Warn("mensagem bloqueada pelos filtros de segurança do LLM", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consigo processar essa mensagem. Se for uma despesa, tente escrever só o valor e a categoria, por exemplo: 50 mercado")
+		return
+	}
+	if reason, reply := degradedReason(err); reason != "" {
+		logger.Warn("LLM não disponível para a mensagem, usando as regras locais", logging.Phone(number), slog.String("reason", reason), slog.Any("error", err))
+		span.SetAttributes(attribute.String("wally.degraded", reason))
+		var ok bool
+		if intent, ok = ruleBasedIntent(message, learned.Entries); !ok {
+			metrics.LLMFallbacks.WithLabelValues(reason, "degraded_reply").Inc()
+			b.messenger.SendMessage(ctx, number, reply)
+			return
+		}
+		metrics.LLMFallbacks.WithLabelValues(reason, "rules").Inc()
+		err = nil
+	}
 
 	if err != nil {
-		log.Printf("Erro ao chamar API Gemini para %s: %v", number, err)
-		wasender.SendMessage(number, "Erro ao conectar com a inteligência artificial. Tente novamente mais tarde.")
+		logger.Error("erro ao chamar o LLM", logging.Phone(number), slog.Any("error", err))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		b.messenger.SendMessage(ctx, number, "Erro ao conectar com a inteligência artificial. Tente novamente mais tarde.")
 		return
 	}
 
-	log.Printf("Intenção detectada pela Gemini para %s: Ação=%s, Parâmetros=%v, Erro=%s", number, intent.Action, intent.Parameters, intent.Error)
+	metrics.IntentsDetected.WithLabelValues(actionLabel(intent.Action)).Inc()
+	span.SetAttributes(attribute.String("wally.action", intent.Action))
+	logger.Info("intenção detectada pelo LLM",
+		slog.String("action", intent.Action),
+		logging.Sensitive("parameters", intent.Parameters),
+		slog.String("intent_error", intent.Error))
 
-	_, originalMessageIfClarifying := sessions.GetAndClearIfPrefix(number, "awaiting_clarification_unknown:")
-	previousStateExpense, _ := sessions.Get(number)
+	_, originalMessageIfClarifying := b.sessions.GetAndClearIfPrefix(number, "awaiting_clarification_unknown:")
+	_, originalMessageIfExpense := b.sessions.GetAndClearIfPrefix(number, awaitingExpensePrefix)
 
 	switch intent.Action {
 	case "add_expense":
 		amountStr, okAmount := intent.Parameters["amount"]
 		category, okCategory := intent.Parameters["category"]
 
+		if okAmount && amountStr != "" && (!okCategory || category == "") {
+			if amount, errConv := ParseAmount(amountStr); errConv == nil {
+				b.askCategory(ctx, number, amount, cmp.Or(originalMessageIfClarifying, originalMessageIfExpense, message))
+				return
+			}
+		}
+
 		if !okAmount || !okCategory || amountStr == "" || category == "" {
 			errorMsg := "Não consegui identificar o valor ou a categoria da despesa."
 			if intent.Error != "" {
 				errorMsg = intent.Error
 			}
-			wasender.SendMessage(number, fmt.Sprintf("%s Poderia tentar novamente? Ex: Adicionar despesa de 50 na categoria Lazer", errorMsg))
-
-			if originalMessageIfClarifying != "" {
-				sessions.Set(number, "awaiting_clarification_unknown:"+message) // Tentar esclarecer a nova mensagem
-			} else {
-				sessions.Set(number, "awaiting_clarification_expense")
-			}
+			b.messenger.SendMessage(ctx, number, fmt.Sprintf("%s Poderia tentar novamente? Ex: Adicionar despesa de 50 na categoria Lazer", errorMsg))
+			b.awaitExpenseClarification(ctx, number, message, originalMessageIfClarifying, originalMessageIfExpense)
 			return
 		}
 
-		amountStr = strings.ReplaceAll(amountStr, ",", ".")
-		re := regexp.MustCompile(`[^\d.]`)
-		amountStr = re.ReplaceAllString(amountStr, "")
-
-		amount, errConv := strconv.ParseFloat(amountStr, 64)
+		amount, errConv := ParseAmount(amountStr)
 		if errConv != nil {
-			wasender.SendMessage(number, fmt.Sprintf("O valor '%s' não parece ser um número válido. Poderia tentar novamente?", amountStr))
-			if originalMessageIfClarifying != "" {
-				sessions.Set(number, "awaiting_clarification_unknown:"+message)
-			} else {
-				sessions.Set(number, "awaiting_clarification_expense")
-			}
+			b.messenger.SendMessage(ctx, number, fmt.Sprintf("O valor '%s' não parece ser um número válido. Poderia tentar novamente?", amountStr))
+			b.awaitExpenseClarification(ctx, number, message, originalMessageIfClarifying, originalMessageIfExpense)
 			return
 		}
 
-		newExpense := domain.Expense{
-			UserID:    number,
-			Amount:    amount,
-			Category:  strings.TrimSpace(category),
-			Timestamp: time.Now(),
-		}
-		log.Printf("Despesa de R$%.2f na categoria %s salva para %s na data %s", newExpense.Amount, newExpense.Category, newExpense.UserID, newExpense.Timestamp.Format("2006-01-02 15:04:05"))
+		category = strings.TrimSpace(category)
+		b.registerExpense(ctx, number, domain.Expense{
+			UserID:   number,
+			Amount:   amount,
+			Category: category,
+		})
+		b.reinforceKnowledge(ctx, number, learned.Entries, intent)
 
-		responseText := fmt.Sprintf("✅ Despesa de R$%.2f na categoria '%s' adicionada com sucesso!", amount, newExpense.Category)
-		wasender.SendMessage(number, responseText)
-
-		clarifiedFromUnknown := originalMessageIfClarifying != ""
-		clarifiedFromExpense := previousStateExpense == "awaiting_clarification_expense"
-
-		if clarifiedFromUnknown || clarifiedFromExpense {
-			entryOriginalQuery := originalMessageIfClarifying
-			if clarifiedFromExpense && !clarifiedFromUnknown {
-				if !clarifiedFromUnknown {
-					log.Printf("RAG: Despesa adicionada após estado 'awaiting_clarification_expense', mas mensagem original não rastreada para aprendizado RAG completo neste fluxo.")
-				}
-			}
-
-			if clarifiedFromUnknown {
-				knowledgeEntry := domain.KnowledgeEntry{
-					UserID:              number,
-					OriginalQuery:       entryOriginalQuery,
-					ClarificationQuery:  message,
-					ResultingAction:     intent.Action,
-					ResultingParameters: intent.Parameters,
-				}
-				if errSave := knowledgeRepo.SaveKnowledge(knowledgeEntry); errSave != nil {
-					log.Printf("Erro ao salvar conhecimento para %s: %v", number, errSave)
-				} else {
-					log.Printf("RAG: Conhecimento salvo (unknown -> add_expense) para %s. Original: '%s', Clarificação: '%s'", number, entryOriginalQuery, message)
-				}
-			}
+		// A mensagem que originou a despesa é a que falhou primeiro, se houve esclarecimento.
+		originalMessage := cmp.Or(originalMessageIfClarifying, originalMessageIfExpense)
+		if originalMessage != "" {
+			b.learnClarification(ctx, number, originalMessage, message, intent)
 		}
-		sessions.Delete(number)
+		b.rememberExpense(ctx, number, lastExpense{
+			Message:  cmp.Or(originalMessage, message),
+			Amount:   amount,
+			Category: category,
+		})
 
 	case "show_menu":
-		wasender.SendMessage(number, utils.BuildMainMenu(name))
+		b.sendMainMenu(ctx, number, name)
+		b.reinforceKnowledge(ctx, number, learned.Entries, intent)
 		if originalMessageIfClarifying != "" {
-			knowledgeEntry := domain.KnowledgeEntry{
-				UserID:              number,
-				OriginalQuery:       originalMessageIfClarifying,
-				ClarificationQuery:  message,
-				ResultingAction:     intent.Action,
-				ResultingParameters: intent.Parameters,
-			}
-			if errSave := knowledgeRepo.SaveKnowledge(knowledgeEntry); errSave != nil {
-				log.Printf("Erro ao salvar conhecimento (menu após unknown) para %s: %v", number, errSave)
-			} else {
-				log.Printf("RAG: Conhecimento salvo (unknown -> show_menu) para %s. Original: '%s', Clarificação: '%s'", number, originalMessageIfClarifying, message)
-			}
+			b.learnClarification(ctx, number, originalMessageIfClarifying, message, intent)
 		}
-		sessions.Delete(number)
+		b.sessions.Delete(number)
 
 	case "unknown_intent":
-		responseText := fallbackConversationalResponse(message, cfg.GeminiKey)
-		wasender.SendMessage(number, responseText)
-		sessions.Set(number, "awaiting_clarification_unknown:"+message)
-		log.Printf("SESSAO: Definido estado 'awaiting_clarification_unknown' para %s com mensagem: '%s'", number, message)
+		responseText := b.conversationalReply(ctx, number, name, message, history, intent.Error, expense)
+		b.messenger.SendMessage(ctx, number, responseText)
+		b.sessions.Set(number, "awaiting_clarification_unknown:"+message)
+		logger.Debug("SESSAO: definido estado 'awaiting_clarification_unknown'", logging.Phone(number))
 
 	default:
-		log.Printf("Ação desconhecida ou não tratada da Gemini: %s", intent.Action)
-		wasender.SendMessage(number, fmt.Sprintf("Desculpe %s, não consegui processar sua solicitação. Tente pedir o 'menu'.", name))
+		logger.Warn("ação desconhecida ou não tratada do LLM", slog.String("action", intent.Action))
+		b.messenger.SendMessage(ctx, number, fmt.Sprintf("Desculpe %s, não consegui processar sua solicitação. Tente pedir o 'menu'.", name))
 		if originalMessageIfClarifying != "" {
-			sessions.Set(number, "awaiting_clarification_unknown:"+message)
-			log.Printf("SESSAO: Mantido/Redefinido estado 'awaiting_clarification_unknown' para %s com nova mensagem: '%s'", number, message)
+			b.sessions.Set(number, "awaiting_clarification_unknown:"+message)
+			logger.Debug("SESSAO: mantido/redefinido estado 'awaiting_clarification_unknown'", logging.Phone(number))
 		} else {
-			sessions.Delete(number)
+			b.sessions.Delete(number)
+		}
+	}
+}
+
+// awaitExpenseClarification guarda, após uma despesa incompleta, a mensagem a ser associada
+// ao esclarecimento. Durante o esclarecimento de uma mensagem não entendida, a nova mensagem
+// passa a ser a esclarecida; caso contrário, fica a primeira que falhou.
+func (b *Bot) awaitExpenseClarification(ctx context.Context, number string, message string, originalMessageIfClarifying string, originalMessageIfExpense string) {
+	if originalMessageIfClarifying != "" {
+		b.sessions.Set(number, "awaiting_clarification_unknown:"+message) // Tentar esclarecer a nova mensagem
+		return
+	}
+	b.sessions.Set(number, awaitingExpensePrefix+cmp.Or(originalMessageIfExpense, message))
+	logging.FromContext(ctx).Debug("SESSAO: definido estado 'awaiting_clarification_expense'", logging.Phone(number))
+}
+
+// learnClarification salva como conhecimento que originalMessage significa a intenção
+// obtida com o esclarecimento.
+func (b *Bot) learnClarification(ctx context.Context, number string, originalMessage string, clarification string, intent IntentResponse) {
+	knowledgeEntry := domain.KnowledgeEntry{
+		UserID:              number,
+		OriginalQuery:       originalMessage,
+		ClarificationQuery:  clarification,
+		ResultingAction:     intent.Action,
+		ResultingParameters: intent.Parameters,
+	}
+	logger := logging.FromContext(ctx)
+	if errSave := b.saveKnowledge(ctx, knowledgeEntry); errors.Is(errSave, errSuspiciousKnowledge) {
+		return
+	} else if errSave != nil {
+		logger.Error("erro ao salvar conhecimento", logging.Phone(number), slog.Any("error", errSave))
+		return
+	}
+	logger.Info("RAG: conhecimento salvo a partir de esclarecimento", logging.Phone(number), slog.String("action", intent.Action))
+}
+
+// reinforceKnowledge reforça as entradas recuperadas que levaram à ação confirmada, isto é,
+// as que têm a mesma ação e categoria da intenção executada.
+func (b *Bot) reinforceKnowledge(ctx context.Context, number string, entries []domain.KnowledgeEntry, intent IntentResponse) {
+	confirmed := domain.KnowledgeEntry{ResultingAction: intent.Action, ResultingParameters: intent.Parameters}
+	var ids []int
+	for _, entry := range entries {
+		if rag.SameMapping(entry, confirmed) {
+			ids = append(ids, entry.ID)
 		}
 	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := b.knowledge.ReinforceKnowledge(ctx, number, ids); err != nil {
+		logging.FromContext(ctx).Error("erro ao reforçar conhecimento", logging.Phone(number), slog.Any("error", err))
+	}
+}
+
+// registerExpense registra a despesa do usuário e envia a confirmação.
+func (b *Bot) registerExpense(ctx context.Context, number string, expense domain.Expense) {
+	if expense.Timestamp.IsZero() {
+		expense.Timestamp = time.Now()
+	}
+	logging.FromContext(ctx).Info("despesa salva",
+		logging.Phone(expense.UserID),
+		logging.Sensitive("amount", expense.Amount),
+		slog.String("category", expense.Category),
+		slog.Time("date", expense.Timestamp))
+
+	responseText := fmt.Sprintf("✅ Despesa de R$%.2f na categoria '%s' adicionada com sucesso!", expense.Amount, expense.Category)
+	b.messenger.SendMessage(ctx, number, responseText)
+}
+
+// actionLabel limita o rótulo das métricas às ações conhecidas, já que o modelo pode
+// devolver qualquer texto em "action".
+func actionLabel(action string) string {
+	switch action {
+	case "add_expense", "show_menu", "unknown_intent":
+		return action
+	}
+	return "other"
 }
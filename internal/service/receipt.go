@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"wally/internal/domain"
+	"wally/internal/llm"
+	"wally/internal/logging"
+	"wally/internal/telemetry"
+	"wally/internal/usage"
+	"wally/pkg/wasender"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const receiptConfirmationPrefix = "awaiting_receipt_confirmation:"
+
+// MediaDownloader baixa o conteúdo de mídias recebidas pelo webhook.
+type MediaDownloader interface {
+	DownloadMedia(ctx context.Context, media domain.MediaMessage) ([]byte, error)
+}
+
+// ReceiptExtractor extrai os dados de um comprovante a partir da imagem.
+type ReceiptExtractor interface {
+	ExtractReceipt(ctx context.Context, image []byte, mimeType string) (domain.Receipt, error)
+}
+
+// WaSenderMediaDownloader baixa mídias através da WaSenderAPI.
+type WaSenderMediaDownloader struct {
+	Client *wasender.Client
+}
+
+func (d WaSenderMediaDownloader) DownloadMedia(ctx context.Context, media domain.MediaMessage) ([]byte, error) {
+	return d.Client.DownloadMedia(ctx, media.MessageID, media.Type, media.Raw)
+}
+
+// LLMReceiptExtractor usa um modelo multimodal para ler comprovantes.
+type LLMReceiptExtractor struct {
+	llm llm.Client
+}
+
+// NewLLMReceiptExtractor cria um extrator de comprovantes que usa o cliente informado.
+func NewLLMReceiptExtractor(client llm.Client) *LLMReceiptExtractor {
+	return &LLMReceiptExtractor{llm: client}
+}
+
+type receiptExtractionResponse struct {
+	Total    string `json:"total"`
+	Merchant string `json:"merchant"`
+	Date     string `json:"date"`
+	Category string `json:"category"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ExtractReceipt envia a imagem para o modelo e retorna total, estabelecimento, data e categoria sugerida.
+func (e *LLMReceiptExtractor) ExtractReceipt(ctx context.Context, image []byte, mimeType string) (domain.Receipt, error) {
+	var receipt domain.Receipt
+
+	prompt := `
+Você receberá a foto de um comprovante, cupom fiscal ou nota de compra.
+Extraia os dados e responda APENAS com um objeto JSON no seguinte formato:
+{
+  "total": "valor_total_pago",
+  "merchant": "nome_do_estabelecimento",
+  "date": "AAAA-MM-DD",
+  "category": "categoria_sugerida",
+  "error": "mensagem_de_erro_se_houver"
+}
+
+Regras:
+- "total" é o valor final pago, como string numérica com ponto decimal (ex: "45.90").
+- "category" deve ser uma categoria curta de despesa pessoal (ex: "Alimentação", "Transporte", "Mercado", "Saúde").
+- Se a imagem não for um comprovante legível, deixe os campos vazios e explique em "error".
+`
+
+	requestPayload := llm.Request{
+		Contents: []llm.Content{
+			{
+				Parts: []llm.Part{
+					{Text: prompt},
+					{InlineData: &llm.InlineData{
+						MimeType: mimeType,
+						Data:     base64.StdEncoding.EncodeToString(image),
+					}},
+				},
+			},
+		},
+		GenerationConfig: &llm.GenerationConfig{
+			ResponseMIMEType: "application/json",
+		},
+	}
+
+	resp, err := e.llm.GenerateContent(ctx, "receipt", requestPayload)
+	if err != nil {
+		return receipt, err
+	}
+	responseText := resp.Text
+	logging.FromContext(ctx).Debug("texto recebido do LLM para comprovante (esperado JSON)", logging.Sensitive("response", responseText))
+
+	var extracted receiptExtractionResponse
+	if err := json.Unmarshal([]byte(responseText), &extracted); err != nil {
+		return receipt, fmt.Errorf("erro ao fazer unmarshal do JSON do comprovante: %w", err)
+	}
+	if extracted.Error != "" && extracted.Total == "" {
+		return receipt, fmt.Errorf("comprovante não reconhecido: %s", extracted.Error)
+	}
+
+	total, err := ParseAmount(extracted.Total)
+	if err != nil {
+		return receipt, fmt.Errorf("valor total '%s' inválido no comprovante: %w", extracted.Total, err)
+	}
+
+	receipt = domain.Receipt{
+		Total:    total,
+		Merchant: strings.TrimSpace(extracted.Merchant),
+		Date:     strings.TrimSpace(extracted.Date),
+		Category: strings.TrimSpace(extracted.Category),
+	}
+	return receipt, nil
+}
+
+// ProcessImageMessage trata fotos de comprovantes: extrai os dados e pede confirmação ao usuário
+// antes de criar a despesa.
+func (b *Bot) ProcessImageMessage(ctx context.Context, number string, name string, media domain.MediaMessage) {
+	ctx = usage.WithUser(ctx, number)
+	ctx, span := telemetry.Start(ctx, "process_image", attribute.String("wally.mime_type", media.MimeType))
+	defer span.End()
+
+	logger := logging.FromContext(ctx)
+	logger.Info("processando imagem", logging.Phone(number), logging.Sensitive("name", name), slog.String("mime_type", media.MimeType))
+
+	image, err := b.media.DownloadMedia(ctx, media)
+	if errors.Is(err, wasender.ErrMediaTooLarge) {
+		logger.Warn("imagem acima do limite de tamanho", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Essa imagem é grande demais para eu ler. Envie uma foto com menos resolução ou digite a despesa. Ex: Gastei 50 com mercado")
+		return
+	}
+	if err != nil {
+		logger.Error("erro ao baixar imagem", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consegui baixar a imagem. Poderia enviar novamente?")
+		return
+	}
+
+	receipt, err := b.receipts.ExtractReceipt(ctx, image, media.MimeType)
+	if errors.Is(err, usage.ErrDailyLimit) {
+		logger.Warn("limite diário de uso do LLM atingido", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "⚠️ Você atingiu o limite diário de leitura de comprovantes. Até amanhã, digite a despesa. Ex: Gastei 50 com mercado")
+		return
+	}
+	if err != nil {
+		logger.Error("erro ao extrair comprovante", logging.Phone(number), slog.Any("error", err))
+		b.messenger.SendMessage(ctx, number, "Não consegui ler o comprovante. Tente uma foto mais nítida ou digite a despesa. Ex: Gastei 50 com mercado")
+		return
+	}
+	if receipt.Category == "" {
+		receipt.Category = "Outros"
+	}
+
+	pending, err := json.Marshal(receipt)
+	if err != nil {
+		logger.Error("erro ao serializar comprovante", logging.Phone(number), slog.Any("error", err))
+		return
+	}
+	b.sessions.Set(number, receiptConfirmationPrefix+string(pending))
+	logger.Debug("SESSAO: definido estado 'awaiting_receipt_confirmation'", logging.Phone(number))
+
+	b.sendReceiptButtons(ctx, number, buildReceiptConfirmation(receipt))
+}
+
+// handleReceiptConfirmation trata a resposta em texto a um comprovante pendente.
+// Retorna true se a mensagem foi consumida pelo fluxo de confirmação.
+func (b *Bot) handleReceiptConfirmation(ctx context.Context, number string, message string) bool {
+	receipt, ok := b.loadPendingReceipt(ctx, number)
+	if !ok {
+		return false
+	}
+
+	switch normalizeAnswer(message) {
+	case "sim", "s", "confirmar", "confirmo", "ok":
+		b.confirmReceipt(ctx, number, receipt)
+	case "nao", "não", "n", "cancelar", "cancela":
+		b.cancelReceipt(ctx, number)
+	default:
+		b.sendReceiptButtons(ctx, number, "Responda *sim* para confirmar a despesa do comprovante ou *não* para cancelar.")
+	}
+	return true
+}
+
+// loadPendingReceipt recupera o comprovante aguardando confirmação do usuário, se houver.
+func (b *Bot) loadPendingReceipt(ctx context.Context, number string) (domain.Receipt, bool) {
+	var receipt domain.Receipt
+
+	state, ok := b.sessions.Get(number)
+	if !ok || !strings.HasPrefix(state, receiptConfirmationPrefix) {
+		return receipt, false
+	}
+
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(state, receiptConfirmationPrefix)), &receipt); err != nil {
+		logging.FromContext(ctx).Error("erro ao ler comprovante pendente", logging.Phone(number), slog.Any("error", err))
+		b.sessions.Delete(number)
+		return receipt, false
+	}
+	return receipt, true
+}
+
+func (b *Bot) confirmReceipt(ctx context.Context, number string, receipt domain.Receipt) {
+	b.sessions.Delete(number)
+	expense := domain.Expense{
+		UserID:   number,
+		Amount:   receipt.Total,
+		Category: receipt.Category,
+	}
+	if date, err := time.ParseInLocation("2006-01-02", receipt.Date, time.Local); err == nil {
+		expense.Timestamp = date
+	}
+	b.registerExpense(ctx, number, expense)
+
+	// O estabelecimento faz o papel da mensagem: a confirmação e uma correção logo depois
+	// ensinam a categoria dele.
+	if receipt.Merchant != "" && receipt.Category != "" {
+		b.learnClarification(ctx, number, receipt.Merchant, "comprovante confirmado", IntentResponse{
+			Action:     "add_expense",
+			Parameters: map[string]string{"amount": strconv.FormatFloat(receipt.Total, 'f', 2, 64), "category": receipt.Category},
+		})
+	}
+	b.rememberExpense(ctx, number, lastExpense{Message: receipt.Merchant, Amount: receipt.Total, Category: receipt.Category})
+}
+
+func (b *Bot) cancelReceipt(ctx context.Context, number string) {
+	if _, ok := b.loadPendingReceipt(ctx, number); ok {
+		b.sessions.Delete(number)
+	}
+	b.messenger.SendMessage(ctx, number, "Tudo bem, descartei o comprovante. Se quiser, digite a despesa. Ex: Gastei 50 com mercado")
+}
+
+func (b *Bot) sendReceiptButtons(ctx context.Context, number string, text string) {
+	b.sendButtons(ctx, number, text, []wasender.Button{
+		{ID: choiceReceiptConfirm, Text: "Confirmar"},
+		{ID: choiceReceiptCancel, Text: "Cancelar"},
+	})
+}
+
+func buildReceiptConfirmation(receipt domain.Receipt) string {
+	var b strings.Builder
+	b.WriteString("🧾 Li o seu comprovante:\n\n")
+	fmt.Fprintf(&b, "💰 Total: R$%.2f\n", receipt.Total)
+	if receipt.Merchant != "" {
+		fmt.Fprintf(&b, "🏪 Estabelecimento: %s\n", receipt.Merchant)
+	}
+	if receipt.Date != "" {
+		fmt.Fprintf(&b, "📅 Data: %s\n", receipt.Date)
+	}
+	fmt.Fprintf(&b, "🏷️ Categoria sugerida: %s\n\n", receipt.Category)
+	b.WriteString("Confirma a despesa?")
+	return b.String()
+}
+
+func normalizeAnswer(message string) string {
+	return strings.Trim(strings.ToLower(strings.TrimSpace(message)), ".!")
+}
+
+// ParseAmount converte valores como "R$ 1.234,56" ou "45.90" em float64.
+func ParseAmount(amountStr string) (float64, error) {
+	amountStr = strings.TrimSpace(amountStr)
+	if strings.Contains(amountStr, ",") {
+		amountStr = strings.ReplaceAll(amountStr, ".", "")
+	}
+	amountStr = strings.ReplaceAll(amountStr, ",", ".")
+	amountStr = nonAmountChars.ReplaceAllString(amountStr, "")
+	return strconv.ParseFloat(amountStr, 64)
+}
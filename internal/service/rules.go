@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"wally/internal/domain"
+	"wally/internal/llm"
+	"wally/internal/rag"
+	"wally/internal/usage"
+)
+
+// Respostas enviadas quando o LLM não pode ser usado e as regras locais não entenderam a
+// mensagem.
+const (
+	degradedReply   = "⚠️ Estou com instabilidade na inteligência artificial agora. Enquanto isso, consigo registrar despesas simples, como \"50 mercado\" ou \"uber 23,90\", e abrir o \"menu\"."
+	dailyLimitReply = "⚠️ Você atingiu o limite diário de uso da inteligência artificial. Até amanhã, consigo registrar despesas simples, como \"50 mercado\" ou \"uber 23,90\", e abrir o \"menu\"."
+)
+
+// degradedReason informa se o erro do LLM leva ao caminho sem IA, com o motivo para logs e
+// métricas e a resposta para quando as regras locais não entenderem a mensagem.
+func degradedReason(err error) (reason string, reply string) {
+	switch {
+	case errors.Is(err, usage.ErrDailyLimit):
+		return "daily_limit", dailyLimitReply
+	case llm.Unavailable(err):
+		return "unavailable", degradedReply
+	default:
+		return "", ""
+	}
+}
+
+// Valores como "30", "23,90", "1.234,56" ou "R$ 15.5". Aplicado à mensagem original, pois a
+// normalização corta a pontuação do fim.
+var ruleAmountPattern = regexp.MustCompile(`\d{1,3}(?:\.\d{3})+(?:,\d{1,2})?|\d+(?:[.,]\d{1,2})?`)
+
+// thousandsOnlyPattern reconhece valores com ponto de milhar e sem centavos, como "1.500".
+// Vale só para o texto digitado pelo usuário: os valores do LLM e dos datasets de avaliação
+// usam ponto decimal, e "4.500" ali é 4,5.
+var thousandsOnlyPattern = regexp.MustCompile(`^\d{1,3}(?:\.\d{3})+$`)
+
+var ruleMenuPattern = regexp.MustCompile(`^(menu|ajuda|help|comandos|oi|ola|bom dia|boa tarde|boa noite)$`)
+
+// ruleCategoryKeywords associa termos comuns, sem acentos, às categorias padrão.
+var ruleCategoryKeywords = map[string][]string{
+	"Alimentação": {"comida", "almoco", "jantar", "lanche", "restaurante", "ifood", "delivery", "padaria", "cafe", "pizza", "rango"},
+	"Transporte":  {"uber", "taxi", "onibus", "metro", "gasolina", "combustivel", "estacionamento", "pedagio"},
+	"Mercado":     {"mercado", "supermercado", "feira", "hortifruti", "sacolao"},
+	"Moradia":     {"aluguel", "condominio", "luz", "agua", "energia", "internet", "gas"},
+	"Saúde":       {"farmacia", "remedio", "medico", "consulta", "exame", "dentista"},
+	"Lazer":       {"cinema", "show", "bar", "cerveja", "viagem", "netflix", "spotify"},
+}
+
+// ruleBasedIntent interpreta a mensagem sem o LLM, para quando o provedor está fora: pedidos
+// de menu e despesas com valor e, se reconhecida, a categoria, vinda do conhecimento
+// aprendido ou de ruleCategoryKeywords. Sem categoria, o bot pergunta qual é. Retorna false
+// se a mensagem não parece nem uma coisa nem outra.
+func ruleBasedIntent(message string, learned []domain.KnowledgeEntry) (IntentResponse, bool) {
+	normalized := normalizeCommand(message)
+	if ruleMenuPattern.MatchString(normalized) {
+		return IntentResponse{Action: "show_menu", Parameters: map[string]string{}}, true
+	}
+
+	match := ruleAmountPattern.FindString(message)
+	if match == "" {
+		return IntentResponse{}, false
+	}
+	if thousandsOnlyPattern.MatchString(match) {
+		match = strings.ReplaceAll(match, ".", "")
+	}
+	amount, err := ParseAmount(match)
+	if err != nil || amount <= 0 {
+		return IntentResponse{}, false
+	}
+
+	params := map[string]string{"amount": strconv.FormatFloat(amount, 'f', 2, 64)}
+	if category := ruleCategory(normalized, learned); category != "" {
+		params["category"] = category
+	}
+	return IntentResponse{Action: "add_expense", Parameters: params}, true
+}
+
+// ruleCategory procura a categoria primeiro nas entradas aprendidas cujos termos (sem os
+// números) aparecem todos na mensagem e depois nos termos comuns.
+func ruleCategory(normalized string, learned []domain.KnowledgeEntry) string {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(normalized, isWordSeparator) {
+		words[word] = true
+	}
+
+	for _, entry := range learned {
+		category := entry.ResultingParameters["category"]
+		if entry.ResultingAction != "add_expense" || category == "" {
+			continue
+		}
+		terms := strings.FieldsFunc(normalizeCommand(rag.NormalizeQuery(entry.OriginalQuery)), isWordSeparator)
+		found := false
+		for _, term := range terms {
+			if isNumber(term) {
+				continue
+			}
+			if found = words[term]; !found {
+				break
+			}
+		}
+		if found {
+			return category
+		}
+	}
+
+	for _, category := range defaultCategories {
+		for _, keyword := range ruleCategoryKeywords[category] {
+			if words[keyword] {
+				return category
+			}
+		}
+	}
+	return ""
+}
+
+func isWordSeparator(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+}
+
+func isNumber(term string) bool {
+	return strings.Trim(term, "0123456789") == ""
+}
@@ -0,0 +1,44 @@
+package service
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"30", 30},
+		{"23,90", 23.90},
+		{"45.90", 45.90},
+		{"0.125", 0.125},
+		{"4.500", 4.5},
+		{"1.234,56", 1234.56},
+		{"R$ 15.5", 15.5},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAmount(%q) = %v, %v; esperado %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestRuleBasedIntentAmount(t *testing.T) {
+	tests := []struct {
+		message  string
+		amount   string
+		category string
+	}{
+		{"aluguel 1.500", "1500.00", "Moradia"},
+		{"carro 1.234.567", "1234567.00", ""},
+		{"uber 23,90", "23.90", "Transporte"},
+		{"mercado 1.234,56", "1234.56", "Mercado"},
+		{"gastei 40", "40.00", ""},
+	}
+	for _, tt := range tests {
+		intent, ok := ruleBasedIntent(tt.message, nil)
+		if !ok || intent.Action != "add_expense" || intent.Parameters["amount"] != tt.amount || intent.Parameters["category"] != tt.category {
+			t.Errorf("ruleBasedIntent(%q) = %+v, %v; esperado %s em %q", tt.message, intent, ok, tt.amount, tt.category)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package sessions
+
+import (
+	"strconv"
+	"strings"
+)
+
+// As opções guardadas por usuário são os IDs oferecidos na última mensagem interativa,
+// na mesma ordem em que foram numeradas no fallback em texto.
+
+// SetChoices registra as opções oferecidas ao usuário.
+func (s *Store) SetChoices(key string, ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.choices[key] = ids
+}
+
+// TakeChoice resolve a resposta do usuário para o ID de uma opção oferecida, aceitando
+// o número da opção (fallback em texto) ou o próprio ID. As opções são descartadas em
+// qualquer caso, pois só valem para a mensagem imediatamente seguinte.
+func (s *Store) TakeChoice(key, text string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, ok := s.choices[key]
+	if !ok {
+		return "", false
+	}
+	delete(s.choices, key)
+
+	text = strings.TrimSpace(text)
+	if n, err := strconv.Atoi(text); err == nil && n >= 1 && n <= len(ids) {
+		return ids[n-1], true
+	}
+	for _, id := range ids {
+		if id == text {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// ClearChoices descarta as opções oferecidas ao usuário.
+func (s *Store) ClearChoices(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.choices, key)
+}
@@ -0,0 +1,99 @@
+package sessions
+
+import "time"
+
+// Papéis dos turnos do histórico, com os mesmos nomes usados pela API da Gemini.
+const (
+	RoleUser = "user"
+	RoleBot  = "model"
+)
+
+// Turn é uma mensagem da conversa: do usuário ou uma resposta do Wally.
+type Turn struct {
+	Role string
+	Text string
+	At   time.Time
+}
+
+// HistoryOptions limita o histórico guardado por usuário: no máximo MaxTurns turnos, nenhum
+// mais antigo que MaxAge. MaxTurns 0 desativa o histórico; MaxAge 0 não expira os turnos.
+// Os estados da conversa (Set) esquecidos há mais de MaxAge também são removidos.
+type HistoryOptions struct {
+	MaxTurns int
+	MaxAge   time.Duration
+}
+
+// DefaultHistoryOptions guarda o suficiente para retomar o assunto das últimas mensagens,
+// sem trazer uma conversa de horas atrás para a mensagem atual.
+var DefaultHistoryOptions = HistoryOptions{MaxTurns: 10, MaxAge: 30 * time.Minute}
+
+// AppendTurn acrescenta um turno ao histórico do usuário, descartando os mais antigos que
+// passarem dos limites. Também remove, no máximo uma vez a cada MaxAge, o histórico e o
+// estado da conversa dos usuários que pararam de conversar, para que os mapas não cresçam
+// sem limite.
+func (s *Store) AppendTurn(key string, turn Turn) {
+	if s.history.MaxTurns <= 0 || turn.Text == "" {
+		return
+	}
+	if turn.At.IsZero() {
+		turn.At = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	turns := append(s.recentTurns(key, turn.At), turn)
+	if len(turns) > s.history.MaxTurns {
+		turns = turns[len(turns)-s.history.MaxTurns:]
+	}
+	s.turns[key] = turns
+	s.sweep(turn.At)
+}
+
+// History retorna os turnos recentes do usuário, do mais antigo para o mais novo.
+func (s *Store) History(key string) []Turn {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	turns := s.recentTurns(key, time.Now())
+	return append([]Turn(nil), turns...)
+}
+
+// ClearHistory apaga o histórico do usuário.
+func (s *Store) ClearHistory(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.turns, key)
+}
+
+// recentTurns retorna os turnos ainda não expirados em now. Deve ser chamado com o lock.
+func (s *Store) recentTurns(key string, now time.Time) []Turn {
+	turns := s.turns[key]
+	if s.history.MaxAge <= 0 {
+		return turns
+	}
+	for i, turn := range turns {
+		if now.Sub(turn.At) <= s.history.MaxAge {
+			return turns[i:]
+		}
+	}
+	return nil
+}
+
+// sweep apaga os históricos em que todos os turnos expiraram em now e os estados definidos
+// há mais de MaxAge, se a última remoção foi há mais de MaxAge. Deve ser chamado com o lock
+// de escrita.
+func (s *Store) sweep(now time.Time) {
+	if s.history.MaxAge <= 0 || now.Sub(s.swept) < s.history.MaxAge {
+		return
+	}
+	s.swept = now
+	for key, turns := range s.turns {
+		if len(turns) == 0 || now.Sub(turns[len(turns)-1].At) > s.history.MaxAge {
+			delete(s.turns, key)
+		}
+	}
+	for key, st := range s.sessions {
+		if now.Sub(st.at) > s.history.MaxAge {
+			delete(s.sessions, key)
+		}
+	}
+}
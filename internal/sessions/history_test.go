@@ -0,0 +1,30 @@
+package sessions
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAppendTurnEvictsExpiredHistories(t *testing.T) {
+	s := NewStoreWithHistory(HistoryOptions{MaxTurns: 10, MaxAge: 30 * time.Minute})
+	start := time.Now().Add(-2 * time.Hour)
+
+	s.AppendTurn("idle", Turn{Role: RoleUser, Text: "uber 30", At: start})
+	s.AppendTurn("active", Turn{Role: RoleUser, Text: "oi", At: start.Add(50 * time.Minute)})
+	if len(s.turns) != 1 {
+		t.Fatalf("histories = %d after the first sweep, want 1 (only active)", len(s.turns))
+	}
+	if _, ok := s.turns["active"]; !ok {
+		t.Fatalf("active history was evicted")
+	}
+
+	// Dentro de MaxAge da última remoção, nada é varrido; depois, sim.
+	s.AppendTurn("other", Turn{Role: RoleUser, Text: "menu", At: start.Add(60 * time.Minute)})
+	if len(s.turns) != 2 {
+		t.Fatalf("histories = %d before MaxAge since the last sweep, want 2", len(s.turns))
+	}
+	s.AppendTurn("other", Turn{Role: RoleUser, Text: "menu", At: start.Add(90 * time.Minute)})
+	if _, ok := s.turns["active"]; ok || len(s.turns) != 1 {
+		t.Errorf("histories = %v, want only other", s.turns)
+	}
+}
@@ -3,46 +3,75 @@ package sessions
 import (
 	"strings"
 	"sync"
+	"time"
 )
 
-var (
-	userSessions = make(map[string]string)
-	mu           sync.RWMutex // Mutex para proteger o acesso concorrente ao mapa
-)
+// Store guarda em memória o estado da conversa de cada usuário, as opções interativas
+// oferecidas a ele e o histórico recente de mensagens. É seguro para uso concorrente.
+type Store struct {
+	mu       sync.RWMutex // Protege o acesso concorrente aos mapas
+	sessions map[string]state
+	choices  map[string][]string
+	turns    map[string][]Turn
+	history  HistoryOptions
+	swept    time.Time // Última remoção dos históricos e estados expirados (veja sweep)
+}
+
+// state é o estado da conversa de um usuário e quando foi definido.
+type state struct {
+	value string
+	at    time.Time
+}
+
+// NewStore cria um armazenamento de sessões vazio, com DefaultHistoryOptions.
+func NewStore() *Store {
+	return NewStoreWithHistory(DefaultHistoryOptions)
+}
+
+// NewStoreWithHistory cria um armazenamento de sessões vazio com os limites de histórico
+// informados.
+func NewStoreWithHistory(history HistoryOptions) *Store {
+	return &Store{
+		sessions: make(map[string]state),
+		choices:  make(map[string][]string),
+		turns:    make(map[string][]Turn),
+		history:  history,
+	}
+}
 
 // Set armazena um valor de sessão para uma chave (número de telefone).
-func Set(key, value string) {
-	mu.Lock()
-	defer mu.Unlock()
-	userSessions[key] = value
+func (s *Store) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[key] = state{value: value, at: time.Now()}
 }
 
 // Get recupera um valor de sessão. Retorna o valor e um booleano indicando se foi encontrado.
-func Get(key string) (string, bool) {
-	mu.RLock()
-	defer mu.RUnlock()
-	value, ok := userSessions[key]
-	return value, ok
+func (s *Store) Get(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st, ok := s.sessions[key]
+	return st.value, ok
 }
 
 // Delete remove uma sessão.
-func Delete(key string) {
-	mu.Lock()
-	defer mu.Unlock()
-	delete(userSessions, key)
+func (s *Store) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
 }
 
 // GetAndClearIfPrefix recupera e remove uma sessão se ela começar com o prefixo especificado.
 // Retorna o valor completo da sessão (fullStateValue) e a parte da string após o prefixo (contentAfterPrefix).
 // Se não corresponder ao prefixo ou não existir, retorna strings vazias para ambos.
-func GetAndClearIfPrefix(key, prefix string) (fullStateValue string, contentAfterPrefix string) {
-	mu.Lock() // Precisa de Lock pois pode deletar
-	defer mu.Unlock()
+func (s *Store) GetAndClearIfPrefix(key, prefix string) (fullStateValue string, contentAfterPrefix string) {
+	s.mu.Lock() // Precisa de Lock pois pode deletar
+	defer s.mu.Unlock()
 
-	val, ok := userSessions[key]
-	if ok && strings.HasPrefix(val, prefix) {
+	st, ok := s.sessions[key]
+	if val := st.value; ok && strings.HasPrefix(val, prefix) {
 		originalContent := strings.TrimPrefix(val, prefix)
-		delete(userSessions, key)
+		delete(s.sessions, key)
 		return val, originalContent
 	}
 	return "", ""
@@ -0,0 +1,86 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wally/internal/logging"
+	"wally/internal/version"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MessageIDKey é o atributo com o ID da mensagem do WhatsApp que originou o span.
+const MessageIDKey = attribute.Key("wally.message_id")
+
+var tracer = otel.Tracer("wally")
+
+// Options configura a exportação dos traces.
+type Options struct {
+	Enabled     bool
+	Endpoint    string // host:porta do coletor OTLP/HTTP, ex: localhost:4318
+	Insecure    bool   // Usa HTTP sem TLS (coletor local em desenvolvimento)
+	SampleRatio float64
+}
+
+// Setup registra o TracerProvider global exportando spans via OTLP/HTTP. Com o tracing
+// desabilitado, o provider padrão (no-op) é mantido. A função retornada faz o flush dos
+// spans pendentes e deve ser chamada no desligamento.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if !opts.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar exportador OTLP: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("wally"),
+		semconv.ServiceVersion(version.Get().Version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao montar resource do OpenTelemetry: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(5*time.Second)),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Start inicia um span filho do span em ctx, anotado com o ID da mensagem em processamento.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if id := logging.CorrelationID(ctx); id != "" {
+		attrs = append(attrs, MessageIDKey.String(id))
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End registra o erro (se houver) no span e o finaliza.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,107 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ngrok sobe o binário do ngrok como processo filho e aguarda o túnel HTTPS ficar pronto
+// consultando a API local do agente.
+type Ngrok struct {
+	Binary  string
+	APIURL  string
+	Port    string
+	Timeout time.Duration
+
+	mu  sync.Mutex
+	cmd *exec.Cmd
+}
+
+type ngrokTunnels struct {
+	Tunnels []struct {
+		Proto     string `json:"proto"`
+		PublicURL string `json:"public_url"`
+	} `json:"tunnels"`
+}
+
+// Start inicia o ngrok e retorna a URL HTTPS pública assim que o túnel estiver disponível.
+func (n *Ngrok) Start(ctx context.Context) (string, error) {
+	n.mu.Lock()
+	n.cmd = exec.Command(n.Binary, "http", n.Port, "--log", "stdout")
+	err := n.cmd.Start()
+	n.mu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("erro ao iniciar o ngrok: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	client := &http.Client{Timeout: time.Second}
+	for {
+		publicURL, err := n.findHTTPSTunnel(ctx, client)
+		if err == nil {
+			slog.Info("túnel ngrok disponível", slog.String("url", publicURL))
+			return publicURL, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			n.Close()
+			return "", fmt.Errorf("ngrok não ficou pronto em %s: %w", n.Timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *Ngrok) findHTTPSTunnel(ctx context.Context, client *http.Client) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimRight(n.APIURL, "/")+"/api/tunnels", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result ngrokTunnels
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	for _, t := range result.Tunnels {
+		if t.Proto == "https" && t.PublicURL != "" {
+			return t.PublicURL, nil
+		}
+	}
+	return "", errors.New("nenhum túnel HTTPS encontrado")
+}
+
+// Close encerra o processo do ngrok, se estiver em execução.
+func (n *Ngrok) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.cmd == nil || n.cmd.Process == nil {
+		return nil
+	}
+	err := n.cmd.Process.Kill()
+	_ = n.cmd.Wait()
+	n.cmd = nil
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("erro ao encerrar o ngrok: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"wally/config"
+)
+
+// Modos de obtenção da URL pública.
+const (
+	ModeNone  = "none"  // Usa a PUBLIC_URL configurada (proxy reverso, ingress etc.)
+	ModeNgrok = "ngrok" // Sobe um túnel ngrok local
+	ModeSkip  = "skip"  // Não registra o webhook na WaSenderAPI
+)
+
+// Strategy define como o Wally obtém a URL pública em que recebe o webhook.
+type Strategy interface {
+	// Start prepara o acesso público e retorna a URL base. Uma URL vazia indica
+	// que o webhook não deve ser registrado.
+	Start(ctx context.Context) (string, error)
+	// Close libera os recursos da estratégia, como processos filhos.
+	Close() error
+}
+
+// New cria a estratégia correspondente a cfg.TunnelMode.
+func New(cfg config.Config) (Strategy, error) {
+	switch cfg.TunnelMode {
+	case ModeNone:
+		return &Static{URL: cfg.PublicUrl}, nil
+	case ModeNgrok:
+		return &Ngrok{
+			Binary:  cfg.NgrokBin,
+			APIURL:  cfg.NgrokApiUrl,
+			Port:    cfg.Port,
+			Timeout: cfg.NgrokTimeout,
+		}, nil
+	case ModeSkip:
+		return Skip{}, nil
+	default:
+		return nil, fmt.Errorf("modo de túnel desconhecido: %q", cfg.TunnelMode)
+	}
+}
+
+// Static usa uma URL pública já conhecida.
+type Static struct {
+	URL string
+}
+
+func (s *Static) Start(ctx context.Context) (string, error) {
+	return strings.TrimRight(s.URL, "/"), nil
+}
+
+func (s *Static) Close() error { return nil }
+
+// Skip não expõe nenhuma URL; o webhook é gerenciado fora do Wally.
+type Skip struct{}
+
+func (Skip) Start(ctx context.Context) (string, error) { return "", nil }
+
+func (Skip) Close() error { return nil }
@@ -0,0 +1,97 @@
+package usage
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryRepository guarda o uso em memória, para testes e execução sem banco.
+type MemoryRepository struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// NewMemoryRepository cria um repositório vazio.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+func (r *MemoryRepository) Record(ctx context.Context, call Call) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if call.CreatedAt.IsZero() {
+		call.CreatedAt = time.Now()
+	}
+	r.calls = append(r.calls, call)
+	return nil
+}
+
+func (r *MemoryRepository) UserTokens(ctx context.Context, userID string, since time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := 0
+	for _, call := range r.calls {
+		if call.UserID == userID && !call.CreatedAt.Before(since) {
+			total += call.Usage.TotalTokens
+		}
+	}
+	return total, nil
+}
+
+func (r *MemoryRepository) Daily(ctx context.Context, since time.Time, loc *time.Location) ([]DailyUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var days []DailyUsage
+	for _, call := range r.calls {
+		if call.CreatedAt.Before(since) {
+			continue
+		}
+		day := StartOfDay(call.CreatedAt, loc)
+		i := slices.IndexFunc(days, func(d DailyUsage) bool {
+			return d.Day.Equal(day) && d.Purpose == call.Purpose && d.Model == call.Model
+		})
+		if i < 0 {
+			days = append(days, DailyUsage{Day: day, Purpose: call.Purpose, Model: call.Model})
+			i = len(days) - 1
+		}
+		days[i].Totals = days[i].add(call)
+	}
+	slices.SortFunc(days, func(a, b DailyUsage) int {
+		return cmp.Or(b.Day.Compare(a.Day), cmp.Compare(a.Purpose, b.Purpose), cmp.Compare(a.Model, b.Model))
+	})
+	return days, nil
+}
+
+func (r *MemoryRepository) ByUser(ctx context.Context, since time.Time, limit int) ([]UserUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []UserUsage
+	for _, call := range r.calls {
+		if call.UserID == "" || call.CreatedAt.Before(since) {
+			continue
+		}
+		i := slices.IndexFunc(users, func(u UserUsage) bool { return u.UserID == call.UserID })
+		if i < 0 {
+			users = append(users, UserUsage{UserID: call.UserID})
+			i = len(users) - 1
+		}
+		users[i].Totals = users[i].add(call)
+	}
+	slices.SortFunc(users, func(a, b UserUsage) int {
+		return cmp.Or(cmp.Compare(b.Usage.TotalTokens, a.Usage.TotalTokens), cmp.Compare(a.UserID, b.UserID))
+	})
+	return users[:min(len(users), limit)], nil
+}
+
+// add soma a chamada aos totais.
+func (t Totals) add(call Call) Totals {
+	t.Calls++
+	t.Usage = t.Usage.Add(call.Usage)
+	if call.CostKnown {
+		t.Cost += call.Cost
+	}
+	return t
+}
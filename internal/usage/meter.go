@@ -0,0 +1,135 @@
+package usage
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"wally/internal/llm"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+)
+
+// MeterOptions controla o limite diário por usuário.
+type MeterOptions struct {
+	DailyTokenLimit int            // Tokens por usuário por dia; 0 desativa o limite
+	Location        *time.Location // Fuso em que o dia começa; nil usa UTC
+	Model           string         // Modelo registrado quando a resposta não informa a versão
+}
+
+// accountant aplica o limite e registra o uso; é comum ao Meter e ao EmbedderMeter, para
+// que geração e embeddings contem no mesmo limite.
+type accountant struct {
+	repo Repository
+	opts MeterOptions
+}
+
+func newAccountant(repo Repository, opts MeterOptions) accountant {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	return accountant{repo: repo, opts: opts}
+}
+
+// Meter registra o uso de cada chamada ao LLM, atribuída ao usuário do contexto (WithUser),
+// e recusa com ErrDailyLimit as chamadas de quem já passou do limite do dia. Deve envolver
+// o cliente de cada modelo, por dentro do hedge: as duas chamadas de um hedge são cobradas.
+type Meter struct {
+	client llm.Client
+	accountant
+}
+
+// NewMeter envolve o cliente com a contabilização de uso.
+func NewMeter(client llm.Client, repo Repository, opts MeterOptions) *Meter {
+	return &Meter{client: client, accountant: newAccountant(repo, opts)}
+}
+
+func (m *Meter) GenerateContent(ctx context.Context, purpose string, req llm.Request) (llm.Response, error) {
+	userID := UserFrom(ctx)
+	if err := m.checkLimit(ctx, userID); err != nil {
+		metrics.LLMErrors.WithLabelValues(purpose, "daily_limit").Inc()
+		return llm.Response{}, err
+	}
+
+	resp, err := m.client.GenerateContent(ctx, purpose, req)
+	if err != nil {
+		return resp, err
+	}
+	m.record(ctx, userID, purpose, resp.Model, resp.Usage)
+	return resp, nil
+}
+
+func (m *Meter) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx)
+}
+
+// EmbedderMeter faz o mesmo que o Meter para os embeddings. A API de embeddings não informa
+// os tokens, então o uso é estimado pelo tamanho do texto.
+type EmbedderMeter struct {
+	embedder llm.Embedder
+	accountant
+}
+
+// NewEmbedderMeter envolve o gerador de embeddings com a contabilização de uso.
+func NewEmbedderMeter(embedder llm.Embedder, repo Repository, opts MeterOptions) *EmbedderMeter {
+	return &EmbedderMeter{embedder: embedder, accountant: newAccountant(repo, opts)}
+}
+
+func (m *EmbedderMeter) Embed(ctx context.Context, text string) ([]float32, error) {
+	const purpose = "embedding"
+	userID := UserFrom(ctx)
+	if err := m.checkLimit(ctx, userID); err != nil {
+		metrics.LLMErrors.WithLabelValues(purpose, "daily_limit").Inc()
+		return nil, err
+	}
+
+	vector, err := m.embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	tokens := llm.EstimateTokens(text)
+	m.record(ctx, userID, purpose, "", llm.Usage{PromptTokens: tokens, TotalTokens: tokens})
+	return vector, nil
+}
+
+// record guarda o uso da chamada e atualiza as métricas.
+func (a accountant) record(ctx context.Context, userID string, purpose string, model string, used llm.Usage) {
+	call := Call{
+		UserID:    userID,
+		Purpose:   purpose,
+		Model:     cmp.Or(model, a.opts.Model, "unknown"),
+		Usage:     used,
+		CreatedAt: time.Now(),
+	}
+	if price, ok := llm.PriceFor(call.Model); ok {
+		call.Cost, call.CostKnown = price.Cost(used), true
+		metrics.LLMCost.WithLabelValues(purpose, call.Model).Add(call.Cost)
+	}
+	metrics.LLMTokens.WithLabelValues(purpose, call.Model, "prompt").Add(float64(used.PromptTokens))
+	metrics.LLMTokens.WithLabelValues(purpose, call.Model, "output").Add(float64(used.OutputTokens))
+
+	// Uma falha ao registrar não deve custar a resposta que já foi paga. O registro não
+	// depende do cancelamento de quem chamou: a chamada perdedora de um hedge é cancelada
+	// assim que a outra responde, mas o que ela consumiu também foi cobrado.
+	if err := a.repo.Record(context.WithoutCancel(ctx), call); err != nil {
+		logging.FromContext(ctx).Warn("erro ao registrar uso do LLM", slog.String("purpose", purpose), slog.Any("error", err))
+	}
+}
+
+// checkLimit retorna ErrDailyLimit se o usuário já gastou o limite do dia. Se o uso não
+// puder ser consultado, a chamada segue: o limite protege o custo, não deve derrubar o bot.
+func (a accountant) checkLimit(ctx context.Context, userID string) error {
+	if userID == "" || a.opts.DailyTokenLimit <= 0 {
+		return nil
+	}
+	used, err := a.repo.UserTokens(ctx, userID, StartOfDay(time.Now(), a.opts.Location))
+	if err != nil {
+		logging.FromContext(ctx).Warn("erro ao consultar uso do LLM; seguindo sem limite", logging.Phone(userID), slog.Any("error", err))
+		return nil
+	}
+	if used >= a.opts.DailyTokenLimit {
+		return fmt.Errorf("%w: %d de %d tokens", ErrDailyLimit, used, a.opts.DailyTokenLimit)
+	}
+	return nil
+}
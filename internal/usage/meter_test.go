@@ -0,0 +1,98 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"wally/internal/llm"
+)
+
+const testUser = "5511999999999"
+
+// lateClient responde depois de delay mesmo se a chamada for cancelada antes, como uma
+// resposta que já estava a caminho quando o hedge escolheu a outra: os tokens foram cobrados.
+type lateClient struct {
+	delay  time.Duration
+	tokens int
+}
+
+func (c lateClient) GenerateContent(ctx context.Context, purpose string, req llm.Request) (llm.Response, error) {
+	time.Sleep(c.delay)
+	return llm.Response{Text: "ok", Usage: llm.Usage{TotalTokens: c.tokens}}, nil
+}
+
+func (c lateClient) Ping(ctx context.Context) error { return nil }
+
+type fixedEmbedder struct{}
+
+func (fixedEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return []float32{1, 0}, nil
+}
+
+// userTokens espera até o uso do usuário chegar a want, já que a chamada perdedora do hedge
+// termina depois da resposta.
+func userTokens(t *testing.T, repo *MemoryRepository, want int) int {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		got, err := repo.UserTokens(context.Background(), testUser, time.Time{})
+		if err != nil {
+			t.Fatalf("UserTokens: %v", err)
+		}
+		if got >= want || time.Now().After(deadline) {
+			return got
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMeterInsideHedgeRecordsBothModels(t *testing.T) {
+	repo := NewMemoryRepository()
+	primary := NewMeter(lateClient{delay: 30 * time.Millisecond, tokens: 10}, repo, MeterOptions{Model: "primary"})
+	secondary := NewMeter(lateClient{delay: 40 * time.Millisecond, tokens: 7}, repo, MeterOptions{Model: "secondary"})
+
+	ctx := WithUser(context.Background(), testUser)
+	resp, err := llm.NewHedged(primary, secondary, 10*time.Millisecond).GenerateContent(ctx, "intent", llm.Request{})
+	if err != nil {
+		t.Fatalf("GenerateContent: %v", err)
+	}
+	if resp.Usage.TotalTokens != 10 {
+		t.Fatalf("winner tokens = %d, want 10 from the primary", resp.Usage.TotalTokens)
+	}
+	if got := userTokens(t, repo, 17); got != 17 {
+		t.Errorf("recorded tokens = %d, want 17 (both hedged calls)", got)
+	}
+
+	models := map[string]bool{}
+	for _, call := range repo.calls {
+		models[call.Model] = true
+	}
+	if !models["primary"] || !models["secondary"] {
+		t.Errorf("recorded models = %v, want primary and secondary", models)
+	}
+}
+
+func TestEmbedderMeter(t *testing.T) {
+	repo := NewMemoryRepository()
+	meter := NewEmbedderMeter(fixedEmbedder{}, repo, MeterOptions{DailyTokenLimit: 5, Model: "text-embedding-004"})
+	ctx := WithUser(context.Background(), testUser)
+
+	// 16 caracteres são estimados em 4 tokens.
+	if _, err := meter.Embed(ctx, "mercado da esqui"); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if got := userTokens(t, repo, 4); got != 4 {
+		t.Errorf("recorded tokens = %d, want 4", got)
+	}
+	if call := repo.calls[0]; call.Purpose != "embedding" || call.Model != "text-embedding-004" {
+		t.Errorf("recorded call = %+v, want purpose embedding and the configured model", call)
+	}
+
+	if _, err := meter.Embed(ctx, "mercado da esquina"); err != nil {
+		t.Fatalf("Embed under the limit: %v", err)
+	}
+	if _, err := meter.Embed(ctx, "padaria"); !errors.Is(err, ErrDailyLimit) {
+		t.Errorf("Embed over the limit: err = %v, want ErrDailyLimit", err)
+	}
+}
@@ -0,0 +1,153 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"wally/internal/metrics"
+	"wally/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var dbSystem = attribute.String("db.system", "postgresql")
+
+// PostgresRepository guarda o uso na tabela llm_usage.
+type PostgresRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+// NewPostgresRepository cria o repositório. Cada consulta é limitada a queryTimeout, além do
+// prazo do contexto recebido.
+func NewPostgresRepository(db *sql.DB, queryTimeout time.Duration) *PostgresRepository {
+	return &PostgresRepository{db: db, queryTimeout: queryTimeout}
+}
+
+func (r *PostgresRepository) Record(ctx context.Context, call Call) (err error) {
+	ctx, span := telemetry.Start(ctx, "db.record_llm_usage", dbSystem, attribute.String("db.operation.name", "INSERT"))
+	defer func() { telemetry.End(span, err) }()
+
+	query := `
+    INSERT INTO llm_usage (user_id, purpose, model, prompt_tokens, output_tokens, total_tokens, cost_usd, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	queryCtx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	createdAt := call.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	cost := sql.NullFloat64{Float64: call.Cost, Valid: call.CostKnown}
+
+	start := time.Now()
+	_, err = r.db.ExecContext(queryCtx, query, call.UserID, call.Purpose, call.Model,
+		call.Usage.PromptTokens, call.Usage.OutputTokens, call.Usage.TotalTokens, cost, createdAt)
+	metrics.DBQueryDuration.WithLabelValues("record_llm_usage").Observe(metrics.Since(start))
+	if err != nil {
+		return fmt.Errorf("erro ao registrar uso do LLM no banco de dados: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) UserTokens(ctx context.Context, userID string, since time.Time) (_ int, err error) {
+	ctx, span := telemetry.Start(ctx, "db.user_llm_tokens", dbSystem, attribute.String("db.operation.name", "SELECT"))
+	defer func() { telemetry.End(span, err) }()
+
+	query := `
+    SELECT coalesce(sum(total_tokens), 0)
+    FROM llm_usage
+    WHERE user_id = $1 AND created_at >= $2`
+
+	queryCtx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var total int
+	start := time.Now()
+	err = r.db.QueryRowContext(queryCtx, query, userID, since).Scan(&total)
+	metrics.DBQueryDuration.WithLabelValues("user_llm_tokens").Observe(metrics.Since(start))
+	if err != nil {
+		return 0, fmt.Errorf("erro ao consultar uso do LLM do usuário: %w", err)
+	}
+	return total, nil
+}
+
+func (r *PostgresRepository) Daily(ctx context.Context, since time.Time, loc *time.Location) (_ []DailyUsage, err error) {
+	ctx, span := telemetry.Start(ctx, "db.daily_llm_usage", dbSystem, attribute.String("db.operation.name", "SELECT"))
+	defer func() { telemetry.End(span, err) }()
+
+	query := `
+    SELECT (created_at AT TIME ZONE $2)::date AS day, purpose, model, count(*),
+        sum(prompt_tokens), sum(output_tokens), sum(total_tokens), coalesce(sum(cost_usd), 0)
+    FROM llm_usage
+    WHERE created_at >= $1
+    GROUP BY day, purpose, model
+    ORDER BY day DESC, purpose, model`
+
+	queryCtx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	rows, err := r.db.QueryContext(queryCtx, query, since, loc.String())
+	metrics.DBQueryDuration.WithLabelValues("daily_llm_usage").Observe(metrics.Since(start))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar uso diário do LLM: %w", err)
+	}
+	defer rows.Close()
+
+	var days []DailyUsage
+	for rows.Next() {
+		var d DailyUsage
+		var day time.Time
+		if err := rows.Scan(&day, &d.Purpose, &d.Model, &d.Calls,
+			&d.Usage.PromptTokens, &d.Usage.OutputTokens, &d.Usage.TotalTokens, &d.Cost); err != nil {
+			return nil, fmt.Errorf("erro ao escanear uso diário do LLM: %w", err)
+		}
+		d.Day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+		days = append(days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro durante iteração do uso diário do LLM: %w", err)
+	}
+	return days, nil
+}
+
+func (r *PostgresRepository) ByUser(ctx context.Context, since time.Time, limit int) (_ []UserUsage, err error) {
+	ctx, span := telemetry.Start(ctx, "db.llm_usage_by_user", dbSystem, attribute.String("db.operation.name", "SELECT"))
+	defer func() { telemetry.End(span, err) }()
+
+	query := `
+    SELECT user_id, count(*), sum(prompt_tokens), sum(output_tokens), sum(total_tokens), coalesce(sum(cost_usd), 0)
+    FROM llm_usage
+    WHERE created_at >= $1 AND user_id <> ''
+    GROUP BY user_id
+    ORDER BY sum(total_tokens) DESC, user_id
+    LIMIT $2`
+
+	queryCtx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	rows, err := r.db.QueryContext(queryCtx, query, since, limit)
+	metrics.DBQueryDuration.WithLabelValues("llm_usage_by_user").Observe(metrics.Since(start))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar uso do LLM por usuário: %w", err)
+	}
+	defer rows.Close()
+
+	var users []UserUsage
+	for rows.Next() {
+		var u UserUsage
+		if err := rows.Scan(&u.UserID, &u.Calls,
+			&u.Usage.PromptTokens, &u.Usage.OutputTokens, &u.Usage.TotalTokens, &u.Cost); err != nil {
+			return nil, fmt.Errorf("erro ao escanear uso do LLM por usuário: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro durante iteração do uso do LLM por usuário: %w", err)
+	}
+	return users, nil
+}
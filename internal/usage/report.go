@@ -0,0 +1,52 @@
+package usage
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// PrintReport escreve o uso por dia, finalidade e modelo e os usuários que mais gastaram.
+// Os números de telefone aparecem mascarados, a não ser com showNumbers.
+func PrintReport(w io.Writer, days []DailyUsage, users []UserUsage, showNumbers bool) {
+	var total Totals
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "DIA\tFINALIDADE\tMODELO\tCHAMADAS\tENTRADA\tSAÍDA\tTOTAL\tCUSTO (US$)\t")
+	for _, d := range days {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Day.Format("2006-01-02"), d.Purpose, d.Model, totalsColumns(d.Totals))
+		total.Calls += d.Calls
+		total.Usage = total.Usage.Add(d.Usage)
+		total.Cost += d.Cost
+	}
+	fmt.Fprintf(tw, "total\t\t\t%s\n", totalsColumns(total))
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	if len(users) == 0 {
+		fmt.Fprintln(w, "Nenhum uso atribuído a usuários no período.")
+		return
+	}
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "USUÁRIO\tCHAMADAS\tENTRADA\tSAÍDA\tTOTAL\tCUSTO (US$)\t")
+	for _, u := range users {
+		userID := u.UserID
+		if !showNumbers {
+			userID = maskNumber(userID)
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", userID, totalsColumns(u.Totals))
+	}
+	tw.Flush()
+}
+
+func totalsColumns(t Totals) string {
+	return fmt.Sprintf("%d\t%d\t%d\t%d\t%.6f\t", t.Calls, t.Usage.PromptTokens, t.Usage.OutputTokens, t.Usage.TotalTokens, t.Cost)
+}
+
+// maskNumber mantém só os quatro últimos dígitos, como nos logs.
+func maskNumber(number string) string {
+	if len(number) <= 4 {
+		return number
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
@@ -0,0 +1,77 @@
+// Package usage contabiliza os tokens e o custo das chamadas ao LLM por usuário e aplica o
+// limite diário de cada um.
+package usage
+
+import (
+	"context"
+	"errors"
+	"time"
+	"wally/internal/llm"
+)
+
+// ErrDailyLimit indica que o usuário já gastou o limite diário de tokens; a chamada não foi
+// feita e o bot deve seguir pelo caminho sem IA.
+var ErrDailyLimit = errors.New("limite diário de uso do LLM atingido")
+
+// Call é o uso de uma chamada ao LLM.
+type Call struct {
+	UserID    string // Vazio para chamadas que não são de um usuário
+	Purpose   string // Finalidade, como nas métricas ("intent", "conversation", "receipt")
+	Model     string
+	Usage     llm.Usage
+	Cost      float64 // USD; só vale com CostKnown
+	CostKnown bool
+	CreatedAt time.Time
+}
+
+// Totals soma o uso de várias chamadas. Cost considera só as chamadas de preço conhecido.
+type Totals struct {
+	Calls int
+	Usage llm.Usage
+	Cost  float64
+}
+
+// DailyUsage é o uso de um dia por finalidade e modelo.
+type DailyUsage struct {
+	Day     time.Time
+	Purpose string
+	Model   string
+	Totals
+}
+
+// UserUsage é o uso de um usuário num período.
+type UserUsage struct {
+	UserID string
+	Totals
+}
+
+// Repository persiste o uso de cada chamada e calcula os agregados.
+type Repository interface {
+	Record(ctx context.Context, call Call) error
+	// UserTokens retorna o total de tokens gastos pelo usuário desde since.
+	UserTokens(ctx context.Context, userID string, since time.Time) (int, error)
+	// Daily retorna o uso desde since por dia (no fuso loc), finalidade e modelo, do dia mais
+	// recente para o mais antigo.
+	Daily(ctx context.Context, since time.Time, loc *time.Location) ([]DailyUsage, error)
+	// ByUser retorna o uso desde since dos limit usuários que mais gastaram tokens.
+	ByUser(ctx context.Context, since time.Time, limit int) ([]UserUsage, error)
+}
+
+type userKey struct{}
+
+// WithUser associa ao contexto o usuário a quem as chamadas ao LLM serão atribuídas.
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// UserFrom retorna o usuário associado ao contexto, se houver.
+func UserFrom(ctx context.Context) string {
+	userID, _ := ctx.Value(userKey{}).(string)
+	return userID
+}
+
+// StartOfDay retorna a meia-noite do dia de t no fuso loc.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	year, month, day := t.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
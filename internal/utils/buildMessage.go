@@ -2,19 +2,33 @@ package utils
 
 import "fmt"
 
-// BuildMainMenu gera a mensagem do menu principal do assistente virtual Wally.
-func BuildMainMenu(name string) string {
-	return fmt.Sprintf(
-		"Olá %s, sou o Wally, seu assistente virtual. Como posso ajudar você hoje?\n\n"+
-			"1️⃣ Adicionar Despesa\n"+
-			"2️⃣ Adicionar Categoria\n"+
-			"3️⃣ Ver extrato\n"+
-			"4️⃣ Ajuda",
-		name,
-	)
+// BuildMenuHeader gera o texto de abertura do menu principal do assistente virtual Wally.
+// As opções do menu são enviadas como lista interativa (ou numeradas no fallback em texto).
+func BuildMenuHeader(name string) string {
+	return fmt.Sprintf("Olá %s, sou o Wally, seu assistente virtual. Como posso ajudar você hoje?", name)
 }
 
 func BuildDespesaAdd() string {
 	return "Para adicionar uma despesa, por favor, informe o valor e a categoria da despesa.\n\n" +
 		"Exemplo: 50.00 Alimentação"
 }
+
+func BuildCategoriaAdd() string {
+	return "Para usar uma nova categoria, basta informá-la ao registrar a despesa.\n\n" +
+		"Exemplo: Gastei 30 com Pet"
+}
+
+func BuildExtratoIndisponivel() string {
+	return "📊 O extrato ainda não está disponível. Em breve você poderá consultar suas despesas por aqui!"
+}
+
+func BuildAjuda() string {
+	return "Você pode falar comigo do jeito que preferir:\n\n" +
+		"✍️ Texto: \"Gastei 25 com café\"\n" +
+		"🧾 Foto: envie a foto de um comprovante ou nota fiscal\n" +
+		"🎙️ Áudio: grave \"gastei trinta e cinco no uber\"\n\n" +
+		"Eu aprendo com as suas respostas. Mande \"o que você aprendeu?\" para ver, " +
+		"\"esquece 2\" ou \"esquece tudo\" para apagar, ou me ensine um atalho: " +
+		"\"quando eu falar 'ifood' é categoria Delivery\".\n\n" +
+		"Para ver as opções novamente, peça o 'menu'."
+}
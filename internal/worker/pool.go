@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// cancelGrace é quanto Close espera pelos jobs depois de cancelá-los.
+const cancelGrace = 5 * time.Second
+
+// ErrClosed indica que o pool já está encerrando e não aceita novos jobs.
+var ErrClosed = errors.New("pool de workers encerrado")
+
+// Job é uma unidade de trabalho. O contexto recebido é cancelado se o desligamento
+// não conseguir drenar a fila a tempo.
+type Job func(ctx context.Context)
+
+// Pool executa jobs em um número fixo de goroutines, com fila limitada.
+// Permite drenar o processamento em andamento no desligamento.
+//
+// Cada worker tem a sua fila, e os jobs de uma mesma chave vão sempre para o mesmo worker.
+// Assim as mensagens de um usuário são processadas em ordem, uma de cada vez, sem disputar
+// o estado da sessão dele.
+type Pool struct {
+	queues []chan Job
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewPool inicia size workers. A capacidade total queueSize é dividida entre as filas dos
+// workers, arredondando para cima.
+func NewPool(size int, queueSize int) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{queues: make([]chan Job, size), ctx: ctx, cancel: cancel}
+	for i := range p.queues {
+		p.queues[i] = make(chan Job, (queueSize+size-1)/size)
+		p.wg.Add(1)
+		go p.run(p.queues[i])
+	}
+	return p
+}
+
+// Submit enfileira um job no worker da chave key, como o número do usuário. Retorna false
+// se a fila desse worker estiver cheia ou o pool encerrando.
+func (p *Pool) Submit(key string, job Job) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	select {
+	case p.queues[h.Sum32()%uint32(len(p.queues))] <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close para de aceitar jobs e aguarda a fila ser drenada. Se ctx expirar antes, o contexto
+// dos jobs é cancelado e Close ainda espera até cancelGrace para que terminem, já que eles
+// usam o banco de dados, fechado logo depois do pool.
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+	}
+
+	select {
+	case <-done:
+		return ctx.Err()
+	case <-time.After(cancelGrace):
+		return fmt.Errorf("%w; jobs ainda em andamento após o cancelamento", ctx.Err())
+	}
+}
+
+func (p *Pool) run(queue chan Job) {
+	defer p.wg.Done()
+	for job := range queue {
+		p.execute(job)
+	}
+}
+
+// execute roda o job protegendo o processo contra panics.
+func (p *Pool) execute(job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("panic ao processar job", slog.Any("panic", r), slog.String("stack", string(debug.Stack())))
+		}
+	}()
+	job(p.ctx)
+}
@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCloseWaitsForCancelledJobs(t *testing.T) {
+	p := NewPool(1, 1)
+	started := make(chan struct{})
+	var finished atomic.Bool
+	p.Submit("5511999999999", func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond) // Ainda usa o banco depois do cancelamento
+		finished.Store(true)
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close() = %v, want DeadlineExceeded", err)
+	}
+	if !finished.Load() {
+		t.Error("Close returned before the cancelled job finished")
+	}
+}
+
+func TestSameKeyRunsInOrder(t *testing.T) {
+	p := NewPool(4, 80) // 20 jobs por fila
+	var running atomic.Int32
+	var order []int
+	for i := range 20 {
+		if !p.Submit("5511999999999", func(ctx context.Context) {
+			if running.Add(1) > 1 {
+				t.Error("two jobs of the same key ran at the same time")
+			}
+			time.Sleep(time.Millisecond)
+			order = append(order, i)
+			running.Add(-1)
+		}) {
+			t.Fatalf("Submit(%d) = false", i)
+		}
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	want := make([]int, 20)
+	for i := range want {
+		want[i] = i
+	}
+	if !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
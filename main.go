@@ -1,28 +1,168 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	_ "time/tzdata" // USAGE_TIMEZONE não depende da base de fusos do sistema
 	"wally/config"
 	"wally/internal/database"
-	"wally/internal/handler"
+	"wally/internal/logging"
+	"wally/internal/telemetry"
+	"wally/internal/tunnel"
+	"wally/internal/worker"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "eval":
+			os.Exit(runEval(os.Args[2:]))
+		case "usage":
+			os.Exit(runUsage(os.Args[2:]))
+		}
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuração inválida:\n%v\n", err)
+		os.Exit(1)
+	}
 
-	if err := database.InitDB(); err != nil {
-		log.Fatalf("Erro ao inicializar o banco de dados: %v", err)
+	if err := logging.Setup(os.Stdout, cfg.LogLevel, cfg.DebugMode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	_, err := config.StartNgrok("8080")
+
+	if err := run(cfg); err != nil {
+		slog.Error("erro fatal", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+// run sobe o servidor e bloqueia até receber SIGINT/SIGTERM, encerrando então o servidor HTTP,
+// o processamento em andamento, o túnel, a limpeza do conhecimento, o banco de dados e, por
+// último, o envio dos spans pendentes, nesta ordem. Os defers são registrados na ordem
+// inversa.
+func run(cfg config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := telemetry.Setup(ctx, telemetry.Options{
+		Enabled:     cfg.TracingEnabled,
+		Endpoint:    cfg.OtlpEndpoint,
+		Insecure:    cfg.OtlpInsecure,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
 	if err != nil {
-		log.Fatalf("Erro ao iniciar o ngrok: %v", err)
+		return fmt.Errorf("erro ao configurar o tracing: %w", err)
 	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("erro ao exportar spans pendentes", slog.Any("error", err))
+		}
+	}()
 
-	http.HandleFunc("/webhook", handler.WebhookHandler)
+	app, err := NewApp(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("erro ao inicializar a aplicação: %w", err)
+	}
+	defer func() {
+		if err := app.Close(); err != nil {
+			slog.Error("erro ao fechar o banco de dados", slog.Any("error", err))
+		}
+	}()
 
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatalf("Erro ao iniciar o servidor: %v", err)
+	if cfg.AutoMigrate {
+		if err := database.MigrateUp(ctx, app.db); err != nil {
+			return fmt.Errorf("erro ao aplicar migrações: %w", err)
+		}
+	}
+
+	// A limpeza usa o banco: é interrompida e aguardada antes de app.Close.
+	pruneCtx, stopPrune := context.WithCancel(ctx)
+	var pruning sync.WaitGroup
+	pruning.Add(1)
+	go func() {
+		defer pruning.Done()
+		app.PruneKnowledge(pruneCtx)
+	}()
+	defer func() {
+		stopPrune()
+		pruning.Wait()
+	}()
+
+	tun, err := tunnel.New(cfg)
+	if err != nil {
+		return fmt.Errorf("erro ao configurar a URL pública: %w", err)
+	}
+	defer func() {
+		if err := tun.Close(); err != nil {
+			slog.Error("erro ao encerrar o túnel", slog.Any("error", err))
+		}
+	}()
+
+	baseURL, err := tun.Start(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao obter a URL pública (%s): %w", cfg.TunnelMode, err)
+	}
+	if baseURL != "" {
+		if err := app.messenger.SetWebhook(ctx, baseURL+"/webhook"); err != nil {
+			return fmt.Errorf("erro ao registrar o webhook: %w", err)
+		}
+		slog.Info("webhook registrado na WaSenderAPI", slog.String("url", baseURL+"/webhook"))
+	} else {
+		slog.Info("registro do webhook desabilitado (TUNNEL_MODE=skip)")
+	}
+
+	pool := worker.NewPool(cfg.Workers, cfg.WorkerQueue)
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           app.Routes(pool),
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("servidor iniciado", slog.String("port", cfg.Port))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		pool.Close(context.Background())
+		return fmt.Errorf("erro ao iniciar o servidor: %w", err)
+	case <-ctx.Done():
+		slog.Info("sinal de desligamento recebido, encerrando")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("erro ao encerrar o servidor HTTP", slog.Any("error", err))
+	}
+	if err := pool.Close(shutdownCtx); err != nil {
+		slog.Warn("processamento pendente não concluído no desligamento", slog.Any("error", err))
 	}
 
-	log.Println("Servidor iniciado na porta 8080")
+	slog.Info("servidor encerrado")
+	return nil
 }
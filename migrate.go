@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"wally/config"
+	"wally/internal/database"
+	"wally/internal/logging"
+)
+
+const migrateUsage = `Uso: wally migrate <comando> [flags]
+
+Comandos:
+  up        aplica todas as migrações pendentes
+  down [n]  reverte as últimas n migrações (padrão: 1)
+  status    lista as migrações e se já foram aplicadas`
+
+// runMigrate implementa o subcomando "wally migrate" e retorna o código de saída.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	command, args := args[0], args[1:]
+
+	steps := 1
+	if command == "down" && len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			steps, args = n, args[1:]
+		}
+	}
+
+	cfg, err := config.Parse(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuração inválida:\n%v\n", err)
+		return 1
+	}
+	if cfg.DatabaseUrl == "" {
+		fmt.Fprintln(os.Stderr, "variavel de ambiente DATABASE_URL nao encontrada")
+		return 1
+	}
+	if err := logging.Setup(os.Stderr, cfg.LogLevel, cfg.DebugMode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.Open(ctx, cfg.DatabaseUrl)
+	if err != nil {
+		slog.Error("erro ao inicializar o banco de dados", slog.Any("error", err))
+		return 1
+	}
+	defer db.Close()
+
+	switch command {
+	case "up":
+		err = database.MigrateUp(ctx, db)
+	case "down":
+		err = database.MigrateDown(ctx, db, steps)
+	case "status":
+		err = printMigrationsStatus(ctx, db)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err != nil {
+		slog.Error("erro ao executar migrações", slog.String("command", command), slog.Any("error", err))
+		return 1
+	}
+	return 0
+}
+
+func printMigrationsStatus(ctx context.Context, db *sql.DB) error {
+	status, err := database.MigrationsStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, s := range status {
+		applied := "pendente"
+		if s.AppliedAt != nil {
+			applied = "aplicada em " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package wasender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+	"wally/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Button é um botão de resposta rápida. O ID volta no webhook quando o usuário toca nele.
+type Button struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// ListRow é uma opção dentro de uma mensagem de lista.
+type ListRow struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// ListSection agrupa opções de uma mensagem de lista.
+type ListSection struct {
+	Title string    `json:"title"`
+	Rows  []ListRow `json:"rows"`
+}
+
+var numberEmojis = []string{"1️⃣", "2️⃣", "3️⃣", "4️⃣", "5️⃣", "6️⃣", "7️⃣", "8️⃣", "9️⃣", "🔟"}
+
+// SendButtons envia uma mensagem com botões de resposta. Se o envio interativo não estiver
+// habilitado (Options.Interactive) ou falhar, envia as opções numeradas em texto.
+func (c *Client) SendButtons(ctx context.Context, number string, text string, buttons []Button) {
+	if c.opts.Interactive {
+		payloadMap := map[string]any{
+			"to":      number,
+			"text":    text,
+			"buttons": buttons,
+		}
+		err := c.postInteractive(ctx, "buttons", payloadMap)
+		if err == nil {
+			metrics.OutboundMessages.WithLabelValues("buttons", "ok").Inc()
+			return
+		}
+		metrics.OutboundMessages.WithLabelValues("buttons", "error").Inc()
+		logging.FromContext(ctx).Warn("erro ao enviar botões, usando texto", logging.Phone(number), slog.Any("error", err))
+	}
+
+	options := make([]string, len(buttons))
+	for i, b := range buttons {
+		options[i] = b.Text
+	}
+	c.SendMessage(ctx, number, text+"\n\n"+renderOptions(options))
+}
+
+// SendList envia uma mensagem de lista de opções. Se o envio interativo não estiver
+// habilitado (Options.Interactive) ou falhar, envia as opções numeradas em texto.
+func (c *Client) SendList(ctx context.Context, number string, text string, buttonText string, sections []ListSection) {
+	if c.opts.Interactive {
+		payloadMap := map[string]any{
+			"to":   number,
+			"text": text,
+			"list": map[string]any{
+				"buttonText": buttonText,
+				"sections":   sections,
+			},
+		}
+		err := c.postInteractive(ctx, "list", payloadMap)
+		if err == nil {
+			metrics.OutboundMessages.WithLabelValues("list", "ok").Inc()
+			return
+		}
+		metrics.OutboundMessages.WithLabelValues("list", "error").Inc()
+		logging.FromContext(ctx).Warn("erro ao enviar lista, usando texto", logging.Phone(number), slog.Any("error", err))
+	}
+
+	var options []string
+	for _, section := range sections {
+		for _, row := range section.Rows {
+			option := row.Title
+			if row.Description != "" {
+				option += " - " + row.Description
+			}
+			options = append(options, option)
+		}
+	}
+	c.SendMessage(ctx, number, text+"\n\n"+renderOptions(options))
+}
+
+func (c *Client) postInteractive(ctx context.Context, kind string, payloadMap map[string]any) (err error) {
+	ctx, span := telemetry.Start(ctx, "wasender.send_interactive", attribute.String("wally.interactive_type", kind))
+	defer func() { telemetry.End(span, err) }()
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	payload, err := json.Marshal(payloadMap)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://www.wasenderapi.com/api/send-message", bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", "Bearer "+c.opts.ApiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("WaSenderAPI retornou status %s", resp.Status)
+	}
+	return nil
+}
+
+// renderOptions numera as opções para o fallback em texto; o usuário responde com o número.
+func renderOptions(options []string) string {
+	lines := make([]string, len(options))
+	for i, option := range options {
+		prefix := fmt.Sprintf("%d.", i+1)
+		if i < len(numberEmojis) {
+			prefix = numberEmojis[i]
+		}
+		lines[i] = prefix + " " + option
+	}
+	return strings.Join(lines, "\n")
+}
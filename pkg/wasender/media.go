@@ -0,0 +1,112 @@
+package wasender
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"wally/internal/logging"
+	"wally/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DefaultMaxMediaBytes é o limite de DownloadMedia quando Options.MaxMediaBytes não é
+// informado: 16 MiB, o maior áudio aceito pelo WhatsApp.
+const DefaultMaxMediaBytes = 16 << 20
+
+// maxErrorBodyBytes limita o corpo de uma resposta de erro copiado para a mensagem de erro.
+const maxErrorBodyBytes = 4 << 10
+
+// ErrMediaTooLarge indica que a mídia passa do limite de Options.MaxMediaBytes.
+var ErrMediaTooLarge = errors.New("mídia maior que o limite permitido")
+
+type decryptMediaResponse struct {
+	Success   bool   `json:"success"`
+	PublicURL string `json:"publicUrl"`
+}
+
+// DownloadMedia descriptografa uma mídia recebida pelo webhook através da WaSenderAPI
+// e retorna o conteúdo do arquivo. Arquivos maiores que Options.MaxMediaBytes não são lidos
+// por inteiro e retornam ErrMediaTooLarge.
+func (c *Client) DownloadMedia(ctx context.Context, messageID string, mediaType string, media json.RawMessage) (_ []byte, err error) {
+	ctx, span := telemetry.Start(ctx, "wasender.download_media", attribute.String("wally.media_type", mediaType))
+	defer func() { telemetry.End(span, err) }()
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	payloadMap := map[string]any{
+		"data": map[string]any{
+			"messages": map[string]any{
+				"key":     map[string]any{"id": messageID},
+				"message": map[string]json.RawMessage{mediaType: media},
+			},
+		},
+	}
+
+	payload, err := json.Marshal(payloadMap)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao montar payload de mídia: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://www.wasenderapi.com/api/decrypt-media", bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição de mídia: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", "Bearer "+c.opts.ApiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao descriptografar mídia: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return nil, fmt.Errorf("WaSenderAPI retornou status %s ao descriptografar mídia: %s", resp.Status, string(body))
+	}
+
+	var decrypted decryptMediaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decrypted); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar resposta de mídia: %w", err)
+	}
+	if !decrypted.Success || decrypted.PublicURL == "" {
+		return nil, fmt.Errorf("WaSenderAPI não retornou a URL da mídia")
+	}
+
+	fileReq, err := http.NewRequestWithContext(ctx, "GET", decrypted.PublicURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição de download: %w", err)
+	}
+	fileResp, err := c.httpClient.Do(fileReq)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao baixar mídia: %w", err)
+	}
+	defer fileResp.Body.Close()
+
+	if fileResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download da mídia retornou status %s", fileResp.Status)
+	}
+
+	limit := cmp.Or(c.opts.MaxMediaBytes, DefaultMaxMediaBytes)
+	if fileResp.ContentLength > limit {
+		return nil, fmt.Errorf("%w: %d bytes (limite de %d)", ErrMediaTooLarge, fileResp.ContentLength, limit)
+	}
+	// O tamanho informado pode faltar ou estar errado: lê no máximo um byte além do limite.
+	data, err := io.ReadAll(io.LimitReader(fileResp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler mídia: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: mais de %d bytes", ErrMediaTooLarge, limit)
+	}
+	logging.FromContext(ctx).Debug("mídia baixada", slog.String("type", mediaType), slog.Int("bytes", len(data)))
+	return data, nil
+}
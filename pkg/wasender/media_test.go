@@ -0,0 +1,60 @@
+package wasender
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// mediaTransport responde à descriptografia com uma URL pública e ao download com body,
+// informando contentLength (-1 quando desconhecido).
+type mediaTransport struct {
+	body          string
+	contentLength int64
+}
+
+func (m mediaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPost {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"success":true,"publicUrl":"https://media.example/file"}`)),
+			Request:    req,
+		}, nil
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		ContentLength: m.contentLength,
+		Body:          io.NopCloser(strings.NewReader(m.body)),
+		Request:       req,
+	}, nil
+}
+
+func TestDownloadMediaLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		wantErr       error
+	}{
+		{"within the limit", "0123456789", 10, nil},
+		{"declared too large", "0123456789a", 11, ErrMediaTooLarge},
+		{"undeclared too large", "0123456789a", -1, ErrMediaTooLarge},
+		{"understated length", "0123456789a", 5, ErrMediaTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(Options{ApiKey: "key", MaxMediaBytes: 10})
+			c.httpClient.Transport = mediaTransport{body: tt.body, contentLength: tt.contentLength}
+
+			data, err := c.DownloadMedia(t.Context(), "id", "imageMessage", []byte(`{}`))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && string(data) != tt.body {
+				t.Errorf("data = %q, want %q", data, tt.body)
+			}
+		})
+	}
+}
@@ -2,13 +2,58 @@ package wasender
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"wally/config"
+	"strconv"
+	"time"
+	"wally/internal/logging"
+	"wally/internal/metrics"
+	"wally/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func SendMessage(number string, message string) {
-	cfg := config.Load()
+// Options configura o acesso à WaSenderAPI.
+type Options struct {
+	ApiKey        string
+	Interactive   bool // Envia botões e listas em vez do fallback em texto
+	Timeout       time.Duration
+	MaxMediaBytes int64 // Maior arquivo aceito por DownloadMedia; 0 usa DefaultMaxMediaBytes
+}
+
+// Client acessa a WaSenderAPI com as credenciais e opções informadas.
+type Client struct {
+	opts       Options
+	httpClient *http.Client
+}
+
+// NewClient cria um cliente da WaSenderAPI.
+func NewClient(o Options) *Client {
+	return &Client{opts: o, httpClient: &http.Client{}}
+}
+
+// withTimeout limita a chamada ao timeout configurado, respeitando o prazo de ctx.
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.opts.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.opts.Timeout)
+}
+
+// SendMessage envia uma mensagem de texto. Falhas são registradas em log e nas métricas,
+// já que o usuário não tem como ser avisado por outro canal.
+func (c *Client) SendMessage(ctx context.Context, number string, message string) {
+	ctx, span := telemetry.Start(ctx, "wasender.send_message")
+	var err error
+	defer func() { telemetry.End(span, err) }()
+	logger := logging.FromContext(ctx)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
 
 	url := "https://www.wasenderapi.com/api/send-message"
 
@@ -19,22 +64,81 @@ func SendMessage(number string, message string) {
 
 	payload, err := json.Marshal(payloadMap)
 	if err != nil {
-		panic(err)
+		logger.Error("erro ao montar mensagem", slog.Any("error", err))
+		return
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payload))
 	if err != nil {
-		panic(err)
+		logger.Error("erro ao criar requisição de envio", slog.Any("error", err))
+		return
 	}
 
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", "Bearer "+cfg.ApiKey)
+	req.Header.Add("Authorization", "Bearer "+c.opts.ApiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		metrics.OutboundMessages.WithLabelValues("text", "error").Inc()
+		logger.Error("erro ao enviar mensagem", logging.Phone(number), slog.Any("error", err))
+		return
+	}
+
+	defer resp.Body.Close()
+	metrics.OutboundMessages.WithLabelValues("text", resultLabel(resp.StatusCode)).Inc()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = fmt.Errorf("WaSenderAPI retornou status %s", resp.Status)
+		logger.Error("erro ao enviar mensagem", logging.Phone(number), slog.Any("error", err))
+		return
+	}
+	logger.Debug("mensagem enviada",
+		logging.Phone(number),
+		slog.Int("status", resp.StatusCode),
+		logging.Sensitive("text", message))
+}
+
+// resultLabel resume o status HTTP da WaSenderAPI para as métricas.
+func resultLabel(statusCode int) string {
+	if statusCode >= 200 && statusCode <= 299 {
+		return "ok"
+	}
+	return "http_" + strconv.Itoa(statusCode)
+}
+
+// SetWebhook registra na WaSenderAPI a URL que receberá os eventos do webhook.
+func (c *Client) SetWebhook(ctx context.Context, webhookURL string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	payload, err := json.Marshal(map[string]string{"url": webhookURL})
+	if err != nil {
+		return err
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://wasenderapi.com/api/set-webhook", bytes.NewBuffer(payload))
 	if err != nil {
-		panic(err)
+		return err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.opts.ApiKey)
 
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao registrar webhook: %w", err)
+	}
 	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("WaSenderAPI retornou status %s ao registrar webhook", resp.Status)
+	}
+	return nil
+}
+
+// CheckCredentials verifica se as credenciais da WaSenderAPI foram configuradas.
+func (c *Client) CheckCredentials() error {
+	if c.opts.ApiKey == "" {
+		return errors.New("API_KEY da WaSenderAPI não configurada")
+	}
+	return nil
 }
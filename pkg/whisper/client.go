@@ -0,0 +1,97 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client transcreve áudios usando o servidor HTTP do whisper.cpp (exemplo "server").
+// O servidor deve ser iniciado com --convert para aceitar áudios OGG/Opus do WhatsApp.
+type Client struct {
+	BaseURL    string
+	Language   string
+	HTTPClient *http.Client
+}
+
+type inferenceResponse struct {
+	Text  string `json:"text"`
+	Error string `json:"error,omitempty"`
+}
+
+// NewClient cria um cliente para o servidor whisper.cpp em baseURL, transcrevendo em português.
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Language:   "pt",
+		HTTPClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Transcribe envia o áudio para o endpoint /inference e retorna o texto transcrito.
+func (c *Client) Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("file", "audio"+extensionFor(mimeType))
+	if err != nil {
+		return "", fmt.Errorf("erro ao montar formulário de áudio: %w", err)
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", fmt.Errorf("erro ao escrever áudio no formulário: %w", err)
+	}
+	_ = writer.WriteField("response_format", "json")
+	_ = writer.WriteField("language", c.Language)
+	_ = writer.WriteField("temperature", "0.0")
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("erro ao finalizar formulário de áudio: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/inference", &body)
+	if err != nil {
+		return "", fmt.Errorf("erro ao criar requisição para o whisper: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("erro ao enviar áudio para o whisper: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("whisper retornou status %s: %s", resp.Status, string(respBody))
+	}
+
+	var result inferenceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("erro ao decodificar resposta do whisper: %w", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("whisper retornou erro: %s", result.Error)
+	}
+
+	return strings.TrimSpace(result.Text), nil
+}
+
+func extensionFor(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "audio/ogg"):
+		return ".ogg"
+	case strings.HasPrefix(mimeType, "audio/mpeg"):
+		return ".mp3"
+	case strings.HasPrefix(mimeType, "audio/mp4"), strings.HasPrefix(mimeType, "audio/aac"):
+		return ".m4a"
+	case strings.HasPrefix(mimeType, "audio/wav"), strings.HasPrefix(mimeType, "audio/x-wav"):
+		return ".wav"
+	default:
+		return ".bin"
+	}
+}
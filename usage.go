@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+	"wally/config"
+	"wally/internal/database"
+	"wally/internal/logging"
+	"wally/internal/usage"
+)
+
+const usageCommandUsage = `Uso: wally usage [flags]
+
+Relata os tokens e o custo das chamadas ao LLM por dia, finalidade (intent, conversation,
+receipt) e modelo, e os usuários que mais consumiram no período. Os dias seguem
+USAGE_TIMEZONE.
+
+Exemplos:
+  wally usage
+  wally usage -days 30 -users 20
+
+Flags:`
+
+// runUsage implementa o subcomando "wally usage" e retorna o código de saída.
+func runUsage(args []string) int {
+	fs := flag.NewFlagSet("wally usage", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), usageCommandUsage)
+		fs.PrintDefaults()
+	}
+	days := fs.Int("days", 7, "quantidade de dias, contando hoje")
+	users := fs.Int("users", 10, "quantidade de usuários listados")
+	showNumbers := fs.Bool("show-numbers", false, "mostra os números de telefone completos")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *days < 1 || *users < 0 {
+		fmt.Fprintln(os.Stderr, "-days deve ser pelo menos 1 e -users não pode ser negativo")
+		return 2
+	}
+
+	cfg, err := config.Parse(nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuração inválida:\n%v\n", err)
+		return 1
+	}
+	if cfg.DatabaseUrl == "" {
+		fmt.Fprintln(os.Stderr, "variavel de ambiente DATABASE_URL nao encontrada")
+		return 1
+	}
+	if err := logging.Setup(os.Stderr, cfg.LogLevel, cfg.DebugMode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx := context.Background()
+	db, err := database.Open(ctx, cfg.DatabaseUrl)
+	if err != nil {
+		slog.Error("erro ao inicializar o banco de dados", slog.Any("error", err))
+		return 1
+	}
+	defer db.Close()
+
+	repo := usage.NewPostgresRepository(db, cfg.DBTimeout)
+	loc := cfg.UsageLocation()
+	since := usage.StartOfDay(time.Now(), loc).AddDate(0, 0, -(*days - 1))
+
+	daily, err := repo.Daily(ctx, since, loc)
+	if err != nil {
+		slog.Error("erro ao consultar uso do LLM", slog.Any("error", err))
+		return 1
+	}
+	var top []usage.UserUsage
+	if *users > 0 {
+		if top, err = repo.ByUser(ctx, since, *users); err != nil {
+			slog.Error("erro ao consultar uso do LLM", slog.Any("error", err))
+			return 1
+		}
+	}
+
+	fmt.Printf("Uso do LLM desde %s (%s)\n\n", since.Format("2006-01-02"), loc)
+	usage.PrintReport(os.Stdout, daily, top, *showNumbers)
+	return 0
+}